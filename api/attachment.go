@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type AttachmentPreview struct {
+	Id     string
+	Url    string
+	Width  int
+	Height int
+}
+
+// Trello card attachment. Can be uploaded file or link
+type Attachment struct {
+	Id        string
+	Bytes     int
+	Date      *time.Time
+	IdMember  string
+	IsUpload  bool
+	MimeType  string
+	Name      string
+	Previews  []*AttachmentPreview
+	Url       string
+	EdgeColor string
+}
+
+// IsImage reports whether the attachment is an uploaded image
+func (a *Attachment) IsImage() bool {
+	return a.IsUpload && strings.HasPrefix(a.MimeType, "image/")
+}
+
+// PreviewURL returns the URL of the biggest preview that is not wider than maxWidth
+func (a *Attachment) PreviewURL(maxWidth int) string {
+	url := ""
+	width := 0
+	for _, p := range a.Previews {
+		if p.Width > width && p.Width <= maxWidth {
+			url = p.Url
+			width = p.Width
+		}
+	}
+	if url == "" && a.IsImage() {
+		return a.Url
+	}
+	return url
+}
+
+// GetAttachment retrieves the card's attachment by id
+func (c *Card) GetAttachment(id string) (*Attachment, error) {
+	b, err := c.c.Request("GET", cardurl+"/"+c.Id+"/attachments/"+id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachment *Attachment
+	err = json.Unmarshal(b, &attachment)
+	return attachment, err
+}
+
+// Cover returns the card's cover attachment if it was fetched
+func (c *Card) Cover() *Attachment {
+	if c.IdAttachmentCover == "" {
+		return nil
+	}
+	for _, a := range c.Attachments {
+		if a.Id == c.IdAttachmentCover {
+			return a
+		}
+	}
+	return nil
+}
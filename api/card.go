@@ -16,21 +16,25 @@ type Card struct {
 	DateLastActivity *time.Time
 	Desc             string
 	//	DescData
-	Due *time.Time
-	Id  string
-	//	IdAttachmentCover
-	Members        []*Member
-	Labels         []*Label
-	Checklists     []*Checklist
-	MemberCreator  *Member
-	IdMembersVoted []string
-	IdMembers      []string
-	IdShort        float64
-	IdBoard        string
-	IdList         string
-	List           *List
-	Board          *Board
-	Actions        []*Action
+	Due               *time.Time
+	DueComplete       bool
+	DueReminder       *int // minutes before the due date, -1 or nil when not set
+	Start             *time.Time
+	Id                string
+	IdAttachmentCover string
+	Attachments       []*Attachment
+	Members           []*Member
+	Labels            []*Label
+	Checklists        []*Checklist
+	MemberCreator     *Member
+	IdMembersVoted    []string
+	IdMembers         []string
+	IdShort           float64
+	IdBoard           string
+	IdList            string
+	List              *List
+	Board             *Board
+	Actions           []*Action
 	//	Labels                []string
 	Name       string
 	Pos        float64
@@ -92,7 +96,7 @@ func (l *List) AddCard(name string, extra url.Values) (*Card, error) {
 
 // Card retrieves a trello card by ID
 func (c *Client) Card(id string) (*Card, error) {
-	b, err := c.Request("GET", cardurl+"/"+id, nil, url.Values{"actions": {"createCard"}, "action_fields": {"idMemberCreator"}, "members": {"true"}, "checkItemStates": {"true"}, "checklists": {"all"}, "board": {"true"}, "list": {"true"}, "membersVoted": {"true"}, "fields": {"badges,checkItemStates,closed,dateLastActivity,desc,due,idBoard,idChecklists,idLabels,idList,idMembers,idShort,labels,name,pos,shortUrl,idMembersVoted"}})
+	b, err := c.Request("GET", cardurl+"/"+id, nil, url.Values{"actions": {"createCard"}, "attachments": {"cover"}, "action_fields": {"idMemberCreator"}, "members": {"true"}, "checkItemStates": {"true"}, "checklists": {"all"}, "board": {"true"}, "list": {"true"}, "membersVoted": {"true"}, "fields": {"badges,checkItemStates,closed,dateLastActivity,desc,due,dueComplete,dueReminder,start,idAttachmentCover,idBoard,idChecklists,idLabels,idList,idMembers,idShort,labels,name,pos,shortUrl,idMembersVoted"}})
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,269 @@
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/decent"
+	"github.com/requilence/integram"
+	tg "github.com/requilence/telegram-bot-api"
+)
+
+const (
+	cardCommentsPageSize = 5
+	cardHistoryPageSize  = 10
+	activityTextLength   = 300 // max length of the comment text in the thread
+)
+
+// action types shown in the card history, labels are not the card's actions in Trello
+const cardHistoryFilter = "createCard,copyCard,commentCard,updateCard,addMemberToCard,removeMemberFromCard," +
+	"addAttachmentToCard,deleteAttachmentFromCard,addChecklistToCard,removeChecklistFromCard," +
+	"updateCheckItemStateOnCard,moveCardToBoard,moveCardFromBoard,convertToCardFromCheckItem"
+
+// cardActions fetches the page of the card actions, the newest first
+func cardActions(c *integram.Context, api *t.Client, cardID string, filter string, page int, pageSize int) ([]action, error) {
+	b, err := api.Request("GET", "cards/"+cardID+"/actions", nil, url.Values{
+		"filter":               {filter},
+		"limit":                {strconv.Itoa(pageSize)},
+		"page":                 {strconv.Itoa(page)},
+		"memberCreator_fields": {"fullName,username"},
+		"member_fields":        {"fullName,username"},
+	})
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []action
+	err = json.Unmarshal(b, &actions)
+	return actions, err
+}
+
+func truncateText(s string, length int) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > length {
+		return string(r[:length-1]) + "…"
+	}
+	return s
+}
+
+// activityText describes the card action for the history
+func activityText(c *integram.Context, a action) string {
+	d := a.Data
+	switch a.Type {
+	case "createCard", "convertToCardFromCheckItem":
+		return "created the card in " + m.Bold(d.List.Name)
+	case "copyCard":
+		return "copied the card"
+	case "commentCard":
+		return "commented: " + m.Italic(truncateText(d.Text, activityTextLength))
+	case "addMemberToCard":
+		if a.Member != nil && a.Member.Id == a.IDMemberCreator {
+			return "joined the card"
+		}
+		return "assigned " + mention(c, a.Member)
+	case "removeMemberFromCard":
+		if a.Member != nil && a.Member.Id == a.IDMemberCreator {
+			return "left the card"
+		}
+		return "unassigned " + mention(c, a.Member)
+	case "addAttachmentToCard":
+		if d.Attachment != nil {
+			return "attached " + m.URL(d.Attachment.Name, d.Attachment.URL)
+		}
+	case "deleteAttachmentFromCard":
+		if d.Attachment != nil {
+			return "deleted the attachment " + m.Bold(d.Attachment.Name)
+		}
+	case "addChecklistToCard":
+		return "added the checklist " + m.Bold(d.Checklist.Name)
+	case "removeChecklistFromCard":
+		return "removed the checklist " + m.Bold(d.Checklist.Name)
+	case "updateCheckItemStateOnCard":
+		if d.CheckItem.State == "complete" {
+			return "completed " + m.Bold(d.CheckItem.Name)
+		}
+		return "marked " + m.Bold(d.CheckItem.Name) + " incomplete"
+	case "moveCardToBoard":
+		if d.BoardSource != nil {
+			return "moved the card from the board " + m.Bold(d.BoardSource.Name)
+		}
+	case "moveCardFromBoard":
+		// d.Board is the source board, the target one has only ID in the action
+		return "moved the card away from the board " + m.Bold(d.Board.Name)
+	case "updateCard":
+		old := d.Old
+		switch {
+		case d.ListAfter != nil && d.ListBefore != nil:
+			return "moved the card from " + m.Bold(d.ListBefore.Name) + " to " + m.Bold(d.ListAfter.Name)
+		case old.has("name"):
+			return "renamed the card from " + m.Italic(old.Name)
+		case old.has("closed"):
+			if d.Card.Closed {
+				return "archived the card"
+			}
+			return "unarchived the card"
+		case old.has("due"):
+			if d.Card.Due != nil && !d.Card.Due.IsZero() {
+				return "set the due date to " + m.Bold(d.Card.Due.In(c.User.TzLocation()).Format(dueDatePreviewFormat))
+			}
+			return "removed the due date"
+		case old.has("dueComplete"):
+			if d.Card.DueComplete {
+				return "marked the due date complete"
+			}
+			return "marked the due date incomplete"
+		case old.has("desc"):
+			return "changed the description"
+		case old.has("pos"):
+			return "moved the card within the list"
+		}
+		return "updated the card"
+	}
+	return "made " + m.EncodeEntities(a.Type)
+}
+
+// cardActivityKeyboard has the navigation row and, for the comments, the buttons to reply every shown comment.
+// Keyboard's state is "comments" or "history", the navigation buttons have the page to open as state
+func cardActivityKeyboard(kind string, actions []action, page int, pageSize int, backData string) integram.InlineKeyboard {
+	kb := integram.InlineKeyboard{State: kind}
+
+	if kind == "comments" {
+		replies := integram.InlineButtons{}
+		for i, a := range actions {
+			replies.Append("r_"+a.ID, fmt.Sprintf("↩️ %d", i+1))
+		}
+		if len(replies) > 0 {
+			kb.AppendRows(replies)
+		}
+	}
+
+	nav := integram.InlineButtons{}
+	if page > 0 {
+		nav.AppendWithState(page-1, "page", "« Newer")
+	}
+	if len(actions) == pageSize {
+		nav.AppendWithState(page+1, "page", "Older »")
+	}
+	nav.Append(backData, "← Back")
+	kb.AppendRows(nav)
+	return kb
+}
+
+// cardActivity renders the page of the card's comments or history
+func cardActivity(c *integram.Context, card *t.Card, kind string, page int, backData string) (string, integram.InlineKeyboard, error) {
+	filter, pageSize, title := "commentCard", cardCommentsPageSize, "💬 Comments"
+	if kind == "history" {
+		filter, pageSize, title = cardHistoryFilter, cardHistoryPageSize, "🕘 History"
+	}
+
+	actions, err := cardActions(c, api(c), card.Id, filter, page, pageSize)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+
+	text := fmt.Sprintf("%s • %s", m.Bold(title), m.URL(card.Name, card.URL()))
+	if page > 0 {
+		text += fmt.Sprintf(", page %d", page+1)
+	}
+	text += "\n"
+
+	if len(actions) == 0 {
+		if kind == "comments" {
+			text += "\nNo comments yet. Reply to the card message to comment it"
+		} else {
+			text += "\nNo activity yet"
+		}
+	}
+
+	loc := c.User.TzLocation()
+	for i, a := range actions {
+		when := m.Italic(decent.Relative(a.Date.In(loc)))
+		if kind == "comments" {
+			text += fmt.Sprintf("\n%d. %s, %s:\n%s\n", i+1, mention(c, &a.MemberCreator), when, m.EncodeEntities(truncateText(a.Data.Text, activityTextLength)))
+		} else {
+			text += fmt.Sprintf("\n• %s %s, %s", mention(c, &a.MemberCreator), activityText(c, a), when)
+		}
+	}
+
+	return text, cardActivityKeyboard(kind, actions, page, pageSize, backData), nil
+}
+
+func openCardActivity(c *integram.Context, card *t.Card, kind string, page int) error {
+	text, kb, err := cardActivity(c, card, kind, page, backButtonData(c))
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery("", false)
+	return c.EditPressedMessageTextAndInlineKeyboard(text, kb)
+}
+
+// cardActivityButtonPressed handles the paging and the comment reply buttons
+func cardActivityButtonPressed(c *integram.Context, card *t.Card, kind string) error {
+	if c.Callback.Data == "page" {
+		return openCardActivity(c, card, kind, c.Callback.State)
+	}
+
+	if !strings.HasPrefix(c.Callback.Data, "r_") {
+		return fmt.Errorf("unknown button %q in the card %s", c.Callback.Data, kind)
+	}
+
+	msg := c.NewMessage()
+	if c.User.IsPrivateStarted() {
+		msg.SetChat(c.User.ID)
+	} else {
+		msg.SetReplyToMsgID(c.Callback.Message.MsgID)
+	}
+	c.AnswerCallbackQuery("", false)
+
+	return msg.SetText(c.User.Mention()+", write the reply to the comment").
+		EnableForceReply().
+		SetSelective(true).
+		SetKeyboard(integram.Button{"cancel", "Cancel"}, true).
+		SetOneTimeKeyboard(true).
+		SetReplyAction(commentReplyEntered, card.Id, strings.TrimPrefix(c.Callback.Data, "r_")).
+		Send()
+}
+
+// commentReplyEntered comments the card quoting the replied comment and mentioning its author
+func commentReplyEntered(c *integram.Context, cardID string, actionID string) error {
+	answer, _ := c.KeyboardAnswer()
+	if answer == "cancel" {
+		return c.NewMessage().SetText("Ok").HideKeyboard().Send()
+	}
+	if strings.TrimSpace(c.Message.Text) == "" {
+		return c.NewMessage().SetText("The reply should be a text").HideKeyboard().Send()
+	}
+	c.SendAction(tg.ChatTyping)
+
+	b, err := api(c).Request("GET", "actions/"+actionID, nil, url.Values{"memberCreator_fields": {"fullName,username"}})
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	var replied action
+	err = json.Unmarshal(b, &replied)
+	if err != nil {
+		return err
+	}
+
+	quote := ""
+	for _, line := range strings.Split(strings.TrimSpace(replied.Data.Text), "\n") {
+		quote += "> " + line + "\n"
+	}
+	text := fmt.Sprintf("%s\n@%s %s", quote, replied.MemberCreator.Username, c.Message.Text)
+
+	c.NewMessage().SetText("Ok").HideKeyboard().Send()
+	_, err = c.Service().DoJob(commentCard, c, cardID, text)
+	return err
+}
@@ -0,0 +1,351 @@
+package trello
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+const newCardCommandHelp = `Create the card in one line:
+/new Fix login #bug @alice ^Doing due:fri 17:00 -- description
+<b>#label</b>, <b>@member</b>, <b>^list</b> and <b>due:</b>date are optional. Everything after <b>--</b> is the description.
+Values with spaces can be quoted: ^"In progress"`
+
+// cardSpec is the card described in one line
+type cardSpec struct {
+	Name    string
+	Labels  []string
+	Members []string
+	List    string
+	Due     string
+	Desc    string
+}
+
+func parseCardSpec(s string) cardSpec {
+	var spec cardSpec
+
+	if i := strings.Index(s, "--"); i >= 0 {
+		spec.Desc = strings.TrimSpace(s[i+2:])
+		s = s[:i]
+	}
+
+	var name []string
+	tokens := splitQuery(s)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case len(token) > 1 && token[0] == '#':
+			spec.Labels = append(spec.Labels, token[1:])
+		case len(token) > 1 && token[0] == '@':
+			spec.Members = append(spec.Members, token[1:])
+		case len(token) > 1 && token[0] == '^':
+			spec.List = token[1:]
+		case strings.HasPrefix(strings.ToLower(token), "due:") && len(token) > 4:
+			spec.Due = token[4:]
+			// the time can follow the date
+			if i+1 < len(tokens) && isDueTime(tokens[i+1]) {
+				spec.Due += " " + tokens[i+1]
+				i++
+			}
+		default:
+			name = append(name, token)
+		}
+	}
+	spec.Name = strings.Join(name, " ")
+	return spec
+}
+
+// resolvedCardSpec contains the Trello IDs of the card attributes and the parts that were not found on the board
+type resolvedCardSpec struct {
+	ListID     string
+	LabelIDs   []string
+	MemberIDs  []string
+	Due        *time.Time
+	Unresolved []string
+}
+
+// bestMatch returns the index of the name that equals to s or contains it. Case-insensitive
+func bestMatch(names []string, s string) int {
+	s = strings.ToLower(s)
+	found := -1
+	for i, name := range names {
+		name = strings.ToLower(name)
+		if name == s {
+			return i
+		}
+		if found == -1 && strings.Contains(name, s) {
+			found = i
+		}
+	}
+	return found
+}
+
+// resolve finds the spec's list, labels and members on the board. defaultListID is used when list is not set or not found
+func (spec cardSpec) resolve(c *integram.Context, boardID string, defaultListID string) (resolvedCardSpec, error) {
+	var res resolvedCardSpec
+
+	lists, members, labels, err := getBoardData(c, api(c), boardID)
+	if err != nil {
+		return res, err
+	}
+	if len(lists) == 0 {
+		return res, errors.New("board has no lists")
+	}
+
+	res.ListID = lists[0].Id
+	if defaultListID != "" && listsFilterByID(lists, defaultListID) != nil {
+		res.ListID = defaultListID
+	}
+
+	if spec.List != "" {
+		var names []string
+		for _, list := range lists {
+			names = append(names, list.Name)
+		}
+		if i := bestMatch(names, spec.List); i >= 0 {
+			res.ListID = lists[i].Id
+		} else {
+			res.Unresolved = append(res.Unresolved, "^"+spec.List)
+		}
+	}
+
+	var labelNames []string
+	for _, label := range labels {
+		name := label.Name
+		if name == "" {
+			name = label.Color
+		}
+		labelNames = append(labelNames, name)
+	}
+	for _, l := range spec.Labels {
+		if i := bestMatch(labelNames, l); i >= 0 {
+			res.LabelIDs = append(res.LabelIDs, labels[i].Id)
+		} else {
+			res.Unresolved = append(res.Unresolved, "#"+l)
+		}
+	}
+
+	var usernames, fullNames []string
+	for _, member := range members {
+		usernames = append(usernames, member.Username)
+		fullNames = append(fullNames, member.FullName)
+	}
+	for _, mem := range spec.Members {
+		i := bestMatch(usernames, mem)
+		if i < 0 {
+			i = bestMatch(fullNames, mem)
+		}
+		if i < 0 && strings.EqualFold(mem, "me") {
+			if me, err := me(c, api(c)); err == nil {
+				res.MemberIDs = append(res.MemberIDs, me.Id)
+				continue
+			}
+		}
+		if i >= 0 {
+			res.MemberIDs = append(res.MemberIDs, members[i].Id)
+		} else {
+			res.Unresolved = append(res.Unresolved, "@"+mem)
+		}
+	}
+
+	if spec.Due != "" {
+		due, err := parseUserDueDate(c, spec.Due)
+		if err == nil {
+			res.Due = &due
+		} else {
+			res.Unresolved = append(res.Unresolved, "due:"+spec.Due)
+		}
+	}
+
+	return res, nil
+}
+
+// createCardFromSpec creates the card with all the attributes in one request
+func createCardFromSpec(c *integram.Context, boardID string, defaultListID string, spec cardSpec) (*t.Card, []string, error) {
+	if spec.Name == "" {
+		return nil, nil, errors.New("card name is empty")
+	}
+
+	res, err := spec.resolve(c, boardID, defaultListID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extra := url.Values{}
+	if len(res.LabelIDs) > 0 {
+		extra.Set("idLabels", strings.Join(res.LabelIDs, ","))
+	}
+	if len(res.MemberIDs) > 0 {
+		extra.Set("idMembers", strings.Join(res.MemberIDs, ","))
+	}
+	if res.Due != nil {
+		extra.Set("due", res.Due.Format(time.RFC3339))
+	}
+	if spec.Desc != "" {
+		extra.Set("desc", spec.Desc)
+	}
+
+	card, err := api(c).CreateCard(spec.Name, res.ListID, extra)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return card, res.Unresolved, nil
+}
+
+// defaultBoardForCard returns the chat's default board or the only integrated one
+func defaultBoardForCard(c *integram.Context) (boardID string, listID string) {
+	cs := chatSettings(c)
+	if cs.DefaultBoard != "" {
+		return cs.DefaultBoard, cs.DefaultList
+	}
+
+	if ids := sortedBoardIDs(cs); len(ids) == 1 {
+		return ids[0], ""
+	}
+	return "", ""
+}
+
+func newCardCommand(c *integram.Context, text string) error {
+	spec := parseCardSpec(text)
+	if spec.Name == "" {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(newCardCommandHelp).
+			EnableHTML().
+			Send()
+	}
+
+	return createCardInDefaultList(c, spec, chatMessage{})
+}
+
+// createCardInDefaultList creates the card in the chat's default list or asks to choose it first.
+// source is the chat message the card is created from, if any
+func createCardInDefaultList(c *integram.Context, spec cardSpec, source chatMessage) error {
+	boardID, listID := defaultBoardForCard(c)
+	if boardID != "" {
+		return createCardFromSpecAndReply(c, boardID, listID, spec, source)
+	}
+
+	buttons, err := boardsButtons(c)
+	if err != nil {
+		return err
+	}
+
+	return c.NewMessage().
+		SetText(fmt.Sprintf("%v select the board to create the card. It will be used by default in this chat", c.User.Mention())).
+		SetKeyboard(buttons.Markup(2), true).
+		SetReplyToMsgID(c.Message.MsgID).
+		SetOneTimeKeyboard(true).
+		SetReplyAction(boardForCardSpecSelected, spec, source).
+		Send()
+}
+
+func boardForCardSpecSelected(c *integram.Context, spec cardSpec, source chatMessage) error {
+	boardID, boardName := c.KeyboardAnswer()
+	if boardID == "" {
+		return nil
+	}
+
+	if spec.List != "" {
+		// list is set explicitly, so no need to ask
+		err := c.Chat.SaveSetting("DefaultBoard", boardID)
+		if err != nil {
+			return err
+		}
+		return createCardFromSpecAndReply(c, boardID, "", spec, source)
+	}
+
+	lists, err := listsByBoardID(c, api(c), boardID)
+	if err != nil {
+		return err
+	}
+	but := integram.Buttons{}
+	for _, list := range lists {
+		but.Append(list.Id, list.Name)
+	}
+
+	return c.NewMessage().
+		SetText("Please choose the default list for cards in "+boardName).
+		SetKeyboard(but.Markup(3), true).
+		SetReplyToMsgID(c.Message.MsgID).
+		SetOneTimeKeyboard(true).
+		SetReplyAction(listForCardSpecSelected, boardID, spec, source).
+		Send()
+}
+
+func listForCardSpecSelected(c *integram.Context, boardID string, spec cardSpec, source chatMessage) error {
+	listID, _ := c.KeyboardAnswer()
+	if listID == "" {
+		return nil
+	}
+
+	err := c.Chat.SaveSetting("DefaultBoard", boardID)
+	if err == nil {
+		err = c.Chat.SaveSetting("DefaultList", listID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return createCardFromSpecAndReply(c, boardID, listID, spec, source)
+}
+
+func createCardFromSpecAndReply(c *integram.Context, boardID string, listID string, spec cardSpec, source chatMessage) error {
+	card, unresolved, err := createCardFromSpec(c, boardID, listID, spec)
+	if err != nil {
+		return err
+	}
+
+	if source.File.FileID != "" {
+		if source.File.FileSize > 10*1024*1024 {
+			unresolved = append(unresolved, "the file is larger than 10MB")
+		} else {
+			_, err = c.Service().DoJob(attachFileToCard, c, card.Id, source.File)
+			if err != nil {
+				c.Log().WithError(err).Error("Can't attach the file to the created card")
+			}
+		}
+	}
+
+	return sendCreatedCard(c, card, unresolved)
+}
+
+// sendCreatedCard posts the just created card with its action keyboard in reply to the current message
+func sendCreatedCard(c *integram.Context, card *t.Card, notes []string) error {
+	api := api(c)
+
+	if lists, err := listsByBoardID(c, api, card.IdBoard); err == nil {
+		card.List = listsFilterByID(lists, card.IdList)
+	}
+	if boards, err := boards(c, api); err == nil {
+		card.Board = boardsFilterByID(boards, card.IdBoard)
+	}
+	card.MemberCreator, _ = me(c, api)
+
+	storeCard(c, card)
+	// createCard webhook will not post the card again in this chat
+	c.Chat.SetCache("card_created_"+card.Id, true, time.Hour)
+
+	text := cardText(c, card)
+	if len(notes) > 0 {
+		text += "\n\n⚠️ Not applied: " + m.EncodeEntities(strings.Join(notes, ", "))
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(text).
+		AddEventID("card_"+card.Id).
+		EnableHTML().
+		SetReplyAction(cardReplied, card.Id).
+		SetInlineKeyboard(cardCreatedKeyboard()).
+		SetCallbackAction(inlineCardButtonPressed, card.Id).
+		Send()
+}
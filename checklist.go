@@ -0,0 +1,355 @@
+package trello
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+	tg "github.com/requilence/telegram-bot-api"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	checkItemStateIncomplete = 0
+	checkItemStateComplete   = 1
+)
+
+// name of the checklist created when the first item is added to the card without checklists
+const defaultChecklistName = "Checklist"
+
+func checklistProgress(checklist *t.Checklist) (done int, total int) {
+	for _, item := range checklist.CheckItems {
+		if item.State == "complete" {
+			done++
+		}
+	}
+	return done, len(checklist.CheckItems)
+}
+
+func checklistByID(card *t.Card, checklistID string) *t.Checklist {
+	for _, checklist := range card.Checklists {
+		if checklist.Id == checklistID {
+			return checklist
+		}
+	}
+	return nil
+}
+
+// checklistKeyboard shows the page of checklist's items as toggle buttons. Keyboard's state is "cl_" + checklist ID.
+// checklist is nil when the card has no checklists yet, so only adding is available
+func checklistKeyboard(card *t.Card, checklist *t.Checklist, page int, backData string) integram.InlineKeyboard {
+	buttons := integram.InlineButtons{}
+	state := "cl_"
+	if checklist != nil {
+		state += checklist.Id
+		for _, item := range checklist.CheckItems {
+			if item.State == "complete" {
+				buttons.AppendWithState(checkItemStateComplete, item.Id, "✅ "+item.Name)
+			} else {
+				buttons.AppendWithState(checkItemStateIncomplete, item.Id, "⬜️ "+item.Name)
+			}
+		}
+	}
+
+	extra := []integram.InlineButton{{Data: "add", Text: "➕ Add item"}}
+	if len(card.Checklists) > 1 {
+		extra = append(extra, integram.InlineButton{Data: "checklist", Text: "☰ Checklists"})
+	}
+	extra = append(extra, integram.InlineButton{Data: backData, Text: "← Back"})
+
+	if len(buttons) == 0 {
+		kb := integram.InlineKeyboard{State: state}
+		kb.AppendRows(extra)
+		return kb
+	}
+
+	kb := inlinePage(buttons, page, "page", state, extra...)
+	kb.FixedWidth = true
+	return kb
+}
+
+// checklistsKeyboard lets to choose the checklist when the card has several ones
+func checklistsKeyboard(card *t.Card, backData string) integram.InlineKeyboard {
+	buttons := integram.InlineButtons{}
+	for _, checklist := range card.Checklists {
+		done, total := checklistProgress(checklist)
+		buttons.Append(checklist.Id, fmt.Sprintf("🚩 %s %d/%d", checklist.Name, done, total))
+	}
+	buttons.Append(backData, "← Back")
+	return buttons.Markup(1, "checklists")
+}
+
+// openChecklist shows the only checklist of the card or the list of them to choose
+func openChecklist(c *integram.Context, card *t.Card) error {
+	c.AnswerCallbackQuery("", false)
+
+	if len(card.Checklists) > 1 {
+		return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), checklistsKeyboard(card, backButtonData(c)))
+	}
+
+	var checklist *t.Checklist
+	if len(card.Checklists) == 1 {
+		checklist = card.Checklists[0]
+	}
+	return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), checklistKeyboard(card, checklist, 0, backButtonData(c)))
+}
+
+// refreshChecklists fetches the card's checklists and stores them in the card cache
+func refreshChecklists(c *integram.Context, api *t.Client, card *t.Card) error {
+	card.SetClient(api)
+	checklists, err := card.GetChecklists()
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	card.Checklists = checklists
+	return c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.checklists": checklists}}, card)
+}
+
+// checklistButtonPressed handles the buttons of the checklist keyboard: toggling items, paging and adding
+func checklistButtonPressed(c *integram.Context, card *t.Card, checklistID string) error {
+	api := api(c)
+	checklist := checklistByID(card, checklistID)
+	backData := backButtonData(c)
+
+	switch c.Callback.Data {
+	case "page":
+		c.AnswerCallbackQuery("", false)
+		return c.EditPressedInlineKeyboard(checklistKeyboard(card, checklist, c.Callback.State, backData))
+	case "checklist":
+		c.AnswerCallbackQuery("", false)
+		return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), checklistsKeyboard(card, backData))
+	case "add":
+		msg := c.NewMessage()
+		if c.User.IsPrivateStarted() {
+			msg.SetChat(c.User.ID)
+		} else {
+			msg.SetReplyToMsgID(c.Callback.Message.MsgID)
+		}
+		c.AnswerCallbackQuery("", false)
+
+		return msg.SetText(c.User.Mention()+", write the new checklist item. Every line will be added as a separate item").
+			EnableForceReply().
+			SetSelective(true).
+			SetKeyboard(integram.Button{"cancel", "Cancel"}, true).
+			SetOneTimeKeyboard(true).
+			SetReplyAction(checkItemEntered, card, checklistID).
+			Send()
+	}
+
+	if checklist == nil {
+		return fmt.Errorf("checklist %s not found on the card %s", checklistID, card.Id)
+	}
+
+	page := 0
+	var item *t.CheckItem
+	for i, ci := range checklist.CheckItems {
+		if ci.Id == c.Callback.Data {
+			item = ci
+			page = i / inlinePageSize
+			break
+		}
+	}
+	if item == nil {
+		return fmt.Errorf("check item %s not found in the checklist %s", c.Callback.Data, checklistID)
+	}
+
+	// the client is set only for the fetched checklist
+	fresh, err := api.Checklist(checklistID)
+	if err == nil {
+		err = fresh.CheckItem(item.Id, c.Callback.State != checkItemStateComplete)
+	}
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = refreshChecklists(c, api, card)
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery("", false)
+	return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), checklistKeyboard(card, checklistByID(card, checklistID), page, backData))
+}
+
+func checkItemEntered(c *integram.Context, card *t.Card, checklistID string) error {
+	action, _ := c.KeyboardAnswer()
+	if action == "cancel" {
+		return c.NewMessage().SetText("Ok").HideKeyboard().Send()
+	}
+
+	api := api(c)
+	var checklist *t.Checklist
+	var err error
+	created := checklistID == ""
+	if created {
+		card.SetClient(api)
+		checklist, err = card.AddChecklist(defaultChecklistName)
+		if err == nil {
+			checklistID = checklist.Id
+		}
+	}
+	if err == nil {
+		checklist, err = api.Checklist(checklistID)
+	}
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(c.Message.Text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		_, err = checklist.AddItem(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = refreshChecklists(c, api, card)
+	if err != nil {
+		return err
+	}
+
+	// card messages with the opened checklist are updated in place, the new items are on the last page
+	checklist = checklistByID(card, checklistID)
+	page := 0
+	if checklist != nil && len(checklist.CheckItems) > 0 {
+		page = (len(checklist.CheckItems) - 1) / inlinePageSize
+	}
+	fromState := "cl_" + checklistID
+	if created {
+		fromState = "cl_"
+	}
+	c.EditMessagesWithEventID("card_"+card.Id, fromState, cardText(c, card), checklistKeyboard(card, checklist, page, "back"))
+
+	return c.NewMessage().SetText("Ok").HideKeyboard().Send()
+}
+
+var checklistItemRe = regexp.MustCompile(`^\s*(?:(?:[-*+•]|\d+[.)])\s+)?(\[[ xX]?\])?\s*(.*?)\s*$`)
+var checklistMarkerRe = regexp.MustCompile(`^\s*(?:[-*+•]|\d+[.)]|\[[ xX]?\])\s`)
+
+type checklistTextItem struct {
+	Name    string
+	Checked bool
+}
+
+// parseChecklistText parses the multi-line list like "- [ ] step", "- [x] step", "* step" or "1. step".
+// The first line is the checklist's name if it is not the list item. ok is false when the text is not a list
+func parseChecklistText(text string) (name string, items []checklistTextItem, ok bool) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil, false
+	}
+
+	if !checklistMarkerRe.MatchString(lines[0] + " ") {
+		name = strings.TrimSuffix(strings.TrimSpace(lines[0]), ":")
+		lines = lines[1:]
+	}
+
+	checkbox := false
+	for _, line := range lines {
+		if !checklistMarkerRe.MatchString(line + " ") {
+			return "", nil, false
+		}
+		im := checklistItemRe.FindStringSubmatch(line)
+		if im[2] == "" {
+			continue
+		}
+		if im[1] != "" {
+			checkbox = true
+		}
+		items = append(items, checklistTextItem{Name: im[2], Checked: strings.ContainsAny(im[1], "xX")})
+	}
+
+	// the single item without the checkbox is rather the ordinary comment
+	if len(items) == 0 || len(items) == 1 && !checkbox {
+		return "", nil, false
+	}
+	return name, items, true
+}
+
+// createChecklistFromText creates the checklist with the items from the text, see parseChecklistText.
+// The job is retried, so the created checklist is stored in the cache and the retry resumes adding its items
+func createChecklistFromText(c *integram.Context, cardID string, text string) error {
+	c.SendAction(tg.ChatTyping)
+
+	name, items, ok := parseChecklistText(text)
+	if !ok {
+		return fmt.Errorf("text is not a checklist")
+	}
+	if name == "" {
+		name = defaultChecklistName
+	}
+
+	api := api(c)
+	card, err := getCard(c, api, cardID)
+	if err != nil {
+		return err
+	}
+	card.SetClient(api)
+
+	key := fmt.Sprintf("checklist_text_%s_%x", cardID, md5.Sum([]byte(text)))
+	checklistID := ""
+	if !c.ServiceCache(key, &checklistID) {
+		created, err := card.AddChecklist(name)
+		if t.IsBadToken(err) {
+			c.User.ResetOAuthToken()
+		}
+		if err != nil {
+			return err
+		}
+		checklistID = created.Id
+		c.SetServiceCache(key, checklistID, time.Hour)
+	}
+
+	// the client is set only for the fetched checklist
+	checklist, err := api.Checklist(checklistID)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	// items are added in order, so the ones the previous attempt added are the first ones
+	for i, item := range items {
+		var ci *t.CheckItem
+		if i < len(checklist.CheckItems) {
+			ci = checklist.CheckItems[i]
+		} else {
+			ci, err = checklist.AddItem(item.Name)
+			if err != nil {
+				return err
+			}
+		}
+		if item.Checked && ci.State != "complete" {
+			err = checklist.CheckItem(ci.Id, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	c.SetServiceCache(key, nil, 0)
+
+	return refreshChecklists(c, api, card)
+}
@@ -0,0 +1,33 @@
+package trello
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChecklistText(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantName  string
+		wantItems []checklistTextItem
+		wantOk    bool
+	}{
+		{"- [ ] one\n- [x] two", "", []checklistTextItem{{"one", false}, {"two", true}}, true},
+		{"Release:\n* build\n* deploy", "Release", []checklistTextItem{{"build", false}, {"deploy", false}}, true},
+		{"1. first\n2) second\n\n3. third", "", []checklistTextItem{{"first", false}, {"second", false}, {"third", false}}, true},
+		{"[X] done\n[] todo", "", []checklistTextItem{{"done", true}, {"todo", false}}, true},
+		{"Todo\n- [ ] single", "Todo", []checklistTextItem{{"single", false}}, true},
+		{"- single", "", nil, false},
+		{"just a comment", "", nil, false},
+		{"Title\n- item\nnot an item", "", nil, false},
+		{"-not a marker\n-neither", "", nil, false},
+		{"", "", nil, false},
+	}
+
+	for _, tt := range tests {
+		name, items, ok := parseChecklistText(tt.in)
+		if ok != tt.wantOk || name != tt.wantName || !reflect.DeepEqual(items, tt.wantItems) {
+			t.Errorf("parseChecklistText(%q) = %q, %v, %v; want %q, %v, %v", tt.in, name, items, ok, tt.wantName, tt.wantItems, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package trello
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	deliveryInstant = iota
+	deliveryHourly
+	deliveryDaily
+)
+
+// kinds of the events summarized in the digest, in the order of appearance
+var digestKinds = []string{"created", "moved", "completed", "commented"}
+
+var digestKindTitles = map[string]string{
+	"created":   "🆕 Created",
+	"moved":     "➡️ Moved",
+	"completed": "✅ Completed",
+	"commented": "💬 Commented",
+}
+
+const digestCommandHelp = `Digest collects created, moved, completed cards and comments into one message per board
+/digest off – send notifications instantly
+/digest hourly – send the digest every hour
+/digest daily 18:00 – send the digest every day at the chosen time`
+
+// ChatDeliverySettings defines how notifications are delivered into the chat
+type ChatDeliverySettings struct {
+	Mode     int // deliveryInstant, deliveryHourly or deliveryDaily
+	Hour     int // local time of the daily digest
+	Minute   int
+	Timezone string // timezone of the user who set the daily digest
+}
+
+// Location returns the timezone of the daily digest
+func (ds ChatDeliverySettings) Location() *time.Location {
+	if ds.Timezone != "" {
+		if loc, err := time.LoadLocation(ds.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// nextTime returns the time of the next digest after from
+func (ds ChatDeliverySettings) nextTime(from time.Time) time.Time {
+	if ds.Mode == deliveryDaily {
+		local := from.In(ds.Location())
+		next := time.Date(local.Year(), local.Month(), local.Day(), ds.Hour, ds.Minute, 0, 0, local.Location())
+		if !next.After(local) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+	return from.Truncate(time.Hour).Add(time.Hour)
+}
+
+// nextDigestTime returns when the buffered events should be sent: at the next digest time, but not earlier than the queued quiet hours end
+func nextDigestTime(cs ChatSettings, from time.Time) time.Time {
+	next := from
+	if cs.Delivery.Mode != deliveryInstant {
+		next = cs.Delivery.nextTime(from)
+	}
+	if cs.Quiet.Queue && cs.Quiet.Active(next) {
+		next = cs.Quiet.End(next)
+	}
+	return next
+}
+
+func (ds ChatDeliverySettings) String() string {
+	switch ds.Mode {
+	case deliveryHourly:
+		return "hourly digest"
+	case deliveryDaily:
+		return fmt.Sprintf("daily digest at %02d:%02d (%s)", ds.Hour, ds.Minute, ds.Location().String())
+	default:
+		return "instant notifications"
+	}
+}
+
+// digestEvent is the buffered notification that will be included into the digest
+type digestEvent struct {
+	ActionID string
+	BoardID  string
+	Kind     string
+	CardID   string
+	CardName string
+	CardURL  string
+	List     string
+	Member   string
+	Date     time.Time
+}
+
+// digestEventKind returns the digest kind of the webhook action or empty string if it is not summarized
+func digestEventKind(wh *webhook, card *t.Card, filter ChatBoardFilterSettings) string {
+	switch wh.Action.Type {
+	case "createCard", "copyCard":
+		if filter.CardCreated {
+			return "created"
+		}
+	case "commentCard":
+		if filter.CardCommented {
+			return "commented"
+		}
+	case "updateCard":
+		old := wh.Action.Data.Old
+		if old == nil {
+			return ""
+		}
+		if old.IDList != "" && filter.CardMoved {
+			return "moved"
+		}
+		if old.has("dueComplete") && card.DueComplete && filter.Due {
+			return "completed"
+		}
+	}
+	return ""
+}
+
+// bufferDigestEvent stores the webhook action to be sent with the next digest and schedules the digest if it is the first event
+func bufferDigestEvent(c *integram.Context, wh *webhook, card *t.Card, cs ChatSettings) {
+	kind := digestEventKind(wh, card, cs.Boards[wh.Model.ID].Filter)
+	if kind == "" {
+		return
+	}
+
+	event := digestEvent{
+		ActionID: wh.Action.ID,
+		BoardID:  wh.Model.ID,
+		Kind:     kind,
+		CardID:   card.Id,
+		CardName: card.Name,
+		CardURL:  card.URL(),
+		Member:   mention(c, &wh.Action.MemberCreator),
+		Date:     wh.Action.Date,
+	}
+	if card.List != nil {
+		event.List = card.List.Name
+	}
+
+	var events []digestEvent
+	// the chat's buffer may already have the action if Trello retried the webhook
+	c.Chat.Cache("digest", &events)
+	for _, buffered := range events {
+		if buffered.ActionID == event.ActionID {
+			return
+		}
+	}
+
+	err := c.Chat.UpdateCache("digest", bson.M{"$push": bson.M{"val": event}}, &events)
+	if err != nil {
+		c.Log().WithError(err).Error("Can't buffer the digest event")
+		return
+	}
+
+	if len(events) == 1 {
+		_, err = c.Service().SheduleJob(sendDigest, 0, nextDigestTime(cs, time.Now()), c)
+		if err != nil {
+			c.Log().WithError(err).Error("Can't schedule the digest")
+		}
+	}
+}
+
+// sendDigest posts the buffered events grouped by board and removes them from the buffer
+func sendDigest(c *integram.Context) error {
+	var events []digestEvent
+	c.Chat.Cache("digest", &events)
+	if len(events) == 0 {
+		return nil
+	}
+
+	cs := chatSettings(c)
+	byBoard := map[string][]digestEvent{}
+	var boardIDs []string
+	for _, event := range events {
+		if _, ok := byBoard[event.BoardID]; !ok {
+			boardIDs = append(boardIDs, event.BoardID)
+		}
+		byBoard[event.BoardID] = append(byBoard[event.BoardID], event)
+	}
+	sort.Slice(boardIDs, func(i, j int) bool {
+		return cs.Boards[boardIDs[i]].Name < cs.Boards[boardIDs[j]].Name
+	})
+
+	for _, boardID := range boardIDs {
+		bs, ok := cs.Boards[boardID]
+		if !ok || !bs.Enabled {
+			continue
+		}
+
+		err := c.NewMessage().
+			SetText(digestText(bs.Name, byBoard[boardID])).
+			EnableHTML().
+			DisableWebPreview().
+			SetSilent(cs.Quiet.Active(time.Now())).
+			Send()
+		if err != nil {
+			return err
+		}
+	}
+
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ActionID
+	}
+
+	// events buffered while the digest was sending will go to the next one
+	var rest []digestEvent
+	err := c.Chat.UpdateCache("digest", bson.M{"$pull": bson.M{"val": bson.M{"actionid": bson.M{"$in": ids}}}}, &rest)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 {
+		_, err = c.Service().SheduleJob(sendDigest, 0, nextDigestTime(chatSettings(c), time.Now()), c)
+	}
+	return err
+}
+
+func digestText(boardName string, events []digestEvent) string {
+	text := "📋 " + m.Bold(boardName) + " digest\n"
+
+	for _, kind := range digestKinds {
+		// the same card is mentioned once per kind: moved shows the last list, commented shows the number of comments
+		var cardIDs []string
+		last := map[string]digestEvent{}
+		count := map[string]int{}
+		for _, event := range events {
+			if event.Kind != kind {
+				continue
+			}
+			if _, ok := last[event.CardID]; !ok {
+				cardIDs = append(cardIDs, event.CardID)
+			}
+			last[event.CardID] = event
+			count[event.CardID]++
+		}
+
+		if len(cardIDs) == 0 {
+			continue
+		}
+
+		text += "\n" + m.Bold(digestKindTitles[kind]) + "\n"
+		for _, id := range cardIDs {
+			event := last[id]
+			line := "• " + m.URL(event.CardName, event.CardURL)
+			switch kind {
+			case "moved":
+				line += " ➔ " + m.Fixed(event.List)
+			case "commented":
+				if count[id] > 1 {
+					line += fmt.Sprintf(" (%d)", count[id])
+				}
+			default:
+				line += " by " + event.Member
+			}
+			text += line + "\n"
+		}
+	}
+	return text
+}
+
+func digestCommand(c *integram.Context, param string) error {
+	cs := chatSettings(c)
+	args := strings.Fields(param)
+	ds := cs.Delivery
+
+	if len(args) == 0 {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("This chat receives " + m.Bold(ds.String()) + "\n\n" + digestCommandHelp).
+			EnableHTML().
+			Send()
+	}
+
+	switch args[0] {
+	case "off", "instant":
+		ds.Mode = deliveryInstant
+	case "hourly":
+		ds.Mode = deliveryHourly
+	case "daily":
+		ds.Mode = deliveryDaily
+		ds.Hour, ds.Minute = 9, 0
+		if len(args) > 1 {
+			tm, err := time.Parse("15:04", args[1])
+			if err != nil {
+				return c.NewMessage().
+					SetReplyToMsgID(c.Message.MsgID).
+					SetText("Wrong time, use the 24-hour format like 18:00").
+					Send()
+			}
+			ds.Hour, ds.Minute = tm.Hour(), tm.Minute()
+		}
+		ds.Timezone = c.User.TzLocation().String()
+	default:
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(digestCommandHelp).
+			Send()
+	}
+
+	err := c.Chat.SaveSetting("Delivery", ds)
+	if err != nil {
+		return err
+	}
+
+	// flush the buffered events when digest is turned off or reschedule them according to the new mode
+	var events []digestEvent
+	if c.Chat.Cache("digest", &events) && len(events) > 0 {
+		cs.Delivery = ds
+		_, err = c.Service().SheduleJob(sendDigest, 0, nextDigestTime(cs, time.Now()), c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText("Done! This chat will receive " + m.Bold(ds.String())).
+		EnableHTML().
+		Send()
+}
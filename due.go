@@ -0,0 +1,313 @@
+package trello
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/requilence/decent"
+	"github.com/requilence/integram"
+)
+
+const (
+	defaultDueHour       = 12 // time of the day when only the date is set
+	dueDatePreviewFormat = "Mon, 02 Jan 2006 15:04"
+)
+
+const dueDateHelp = "e.g. <b>tomorrow 9am</b>, <b>next friday</b>, <b>in 3 days</b>, <b>eod</b>, <b>25.12 18:00</b> or <b>2020-12-25</b>"
+
+var (
+	dueTimeRe      = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	dueISODateRe   = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	dueDotDateRe   = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{2}|\d{4}))?$`)
+	dueSlashDateRe = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})(?:/(\d{2}|\d{4}))?$`)
+	dueDayRe       = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+	dueRelativeRe  = regexp.MustCompile(`^(\d+|an?)\s*(m|mins?|minutes?|h|hrs?|hours?|d|days?|w|wks?|weeks?|mo|months?)$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// isDueTime reports whether the token is the time of the day like "17:00", "9am" or "noon".
+// Bare numbers are not treated as time to keep them for dates like "25 dec"
+func isDueTime(token string) bool {
+	token = strings.ToLower(token)
+	if token == "noon" || token == "midnight" {
+		return true
+	}
+	tm := dueTimeRe.FindStringSubmatch(token)
+	return tm != nil && (tm[2] != "" || tm[3] != "")
+}
+
+func parseDueTime(token string) (hour int, min int, err error) {
+	switch token {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+
+	tm := dueTimeRe.FindStringSubmatch(token)
+	if tm == nil {
+		return 0, 0, fmt.Errorf("can't parse the time %q", token)
+	}
+	hour, _ = strconv.Atoi(tm[1])
+	min, _ = strconv.Atoi(tm[2])
+
+	if tm[3] != "" {
+		if hour == 0 || hour > 12 {
+			return 0, 0, fmt.Errorf("wrong time %q", token)
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if tm[3] == "pm" {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || min > 59 {
+		return 0, 0, fmt.Errorf("wrong time %q", token)
+	}
+	return hour, min, nil
+}
+
+// dateMonthFirst reports whether the user's locale writes the month before the day, e.g. 12/25
+func dateMonthFirst(lang string) bool {
+	return strings.EqualFold(lang, "en-us")
+}
+
+// parseDueDate parses the due date relative to now in now's location.
+// It understands "today", "tomorrow 9am", "fri", "next friday", "in 3 days", "in 2 hours", "eod", "eow", "eom",
+// "25.12 18:00", "25 dec", "dec 25 2020", "2020-12-25" and RFC3339.
+// Numeric dates with slashes are read as mm/dd when monthFirst is set and as dd/mm otherwise.
+// When only the date is set the time is defaultDueHour. Dates without the year are the nearest ones in the future
+func parseDueDate(s string, now time.Time, monthFirst bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(now.Location()), nil
+	}
+
+	var tokens []string
+	for _, token := range strings.Fields(strings.ToLower(strings.Replace(s, ",", " ", -1))) {
+		switch token {
+		case "at", "on", "by":
+			continue
+		case "am", "pm":
+			// "9 am"
+			if len(tokens) > 0 && dueTimeRe.MatchString(tokens[len(tokens)-1]) {
+				tokens[len(tokens)-1] += token
+				continue
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	if len(tokens) == 0 {
+		return time.Time{}, fmt.Errorf("empty due date")
+	}
+
+	hour, min := defaultDueHour, 0
+	timeSet := false
+	var dateTokens []string
+	for _, token := range tokens {
+		if !timeSet && isDueTime(token) {
+			var err error
+			hour, min, err = parseDueTime(token)
+			if err != nil {
+				return time.Time{}, err
+			}
+			timeSet = true
+			continue
+		}
+		dateTokens = append(dateTokens, token)
+	}
+	day := strings.Join(dateTokens, " ")
+
+	loc := now.Location()
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, 0, 0, loc)
+	}
+	today := date(now.Year(), now.Month(), now.Day())
+	endOfDay := func(t time.Time) time.Time {
+		if timeSet {
+			return t
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 0, 0, loc)
+	}
+
+	switch day {
+	case "":
+		// only the time: today or tomorrow if it has passed
+		if today.Before(now) {
+			return today.AddDate(0, 0, 1), nil
+		}
+		return today, nil
+	case "today", "tod":
+		return today, nil
+	case "tomorrow", "tmr", "tmrw":
+		return today.AddDate(0, 0, 1), nil
+	case "day after tomorrow":
+		return today.AddDate(0, 0, 2), nil
+	case "eod", "end of day":
+		return endOfDay(today), nil
+	case "eow", "end of week":
+		return endOfDay(today.AddDate(0, 0, (7-int(now.Weekday()))%7)), nil
+	case "eom", "end of month":
+		return endOfDay(date(now.Year(), now.Month()+1, 0)), nil
+	case "next week":
+		return today.AddDate(0, 0, 7-(int(now.Weekday())+6)%7), nil
+	case "next month":
+		return date(now.Year(), now.Month()+1, 1), nil
+	}
+
+	if strings.HasPrefix(day, "in ") {
+		return parseRelativeDue(strings.TrimPrefix(day, "in "), now, today)
+	}
+
+	if wd, ok := weekdays[strings.TrimPrefix(day, "this ")]; ok {
+		// the nearest weekday in the future, today counts if the time is still ahead
+		due := today.AddDate(0, 0, (int(wd)-int(now.Weekday())+7)%7)
+		if due.Before(now) {
+			due = due.AddDate(0, 0, 7)
+		}
+		return due, nil
+	}
+
+	if wd, ok := weekdays[strings.TrimPrefix(day, "next ")]; ok && strings.HasPrefix(day, "next ") {
+		// the weekday of the next week, weeks start on Monday
+		monday := today.AddDate(0, 0, 7-(int(now.Weekday())+6)%7)
+		return monday.AddDate(0, 0, (int(wd)+6)%7), nil
+	}
+
+	if dm := dueISODateRe.FindStringSubmatch(day); dm != nil {
+		return validDueDate(date, atoi(dm[1]), atoi(dm[2]), atoi(dm[3]))
+	}
+
+	if dm := dueDotDateRe.FindStringSubmatch(day); dm != nil {
+		return dueDateNearest(date, now, atoi(dm[2]), atoi(dm[1]), dm[3])
+	}
+
+	if dm := dueSlashDateRe.FindStringSubmatch(day); dm != nil {
+		if monthFirst {
+			return dueDateNearest(date, now, atoi(dm[1]), atoi(dm[2]), dm[3])
+		}
+		return dueDateNearest(date, now, atoi(dm[2]), atoi(dm[1]), dm[3])
+	}
+
+	// "25 dec", "dec 25", "december 25th 2020"
+	if len(dateTokens) == 2 || len(dateTokens) == 3 {
+		year := ""
+		if len(dateTokens) == 3 {
+			year = dateTokens[2]
+			if _, err := strconv.Atoi(year); err != nil {
+				return time.Time{}, fmt.Errorf("can't parse the year %q", year)
+			}
+		}
+		if month, ok := months[dateTokens[0]]; ok && dueDayRe.MatchString(dateTokens[1]) {
+			return dueDateNearest(date, now, int(month), atoi(dueDayRe.FindStringSubmatch(dateTokens[1])[1]), year)
+		}
+		if month, ok := months[dateTokens[1]]; ok && dueDayRe.MatchString(dateTokens[0]) {
+			return dueDateNearest(date, now, int(month), atoi(dueDayRe.FindStringSubmatch(dateTokens[0])[1]), year)
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("can't parse the date %q", day)
+}
+
+// parseRelativeDue parses the offset like "3 days", "2h" or "a week". Minutes and hours are added to now,
+// the longer offsets are added to today keeping the time of the day
+func parseRelativeDue(s string, now time.Time, today time.Time) (time.Time, error) {
+	rm := dueRelativeRe.FindStringSubmatch(s)
+	if rm == nil {
+		return time.Time{}, fmt.Errorf("can't parse the offset %q", s)
+	}
+
+	n := 1
+	if rm[1] != "a" && rm[1] != "an" {
+		n = atoi(rm[1])
+	}
+
+	switch unit := rm[2]; {
+	case unit == "m" || strings.HasPrefix(unit, "min"):
+		return now.Add(time.Duration(n) * time.Minute).Truncate(time.Minute), nil
+	case strings.HasPrefix(unit, "h"):
+		return now.Add(time.Duration(n) * time.Hour).Truncate(time.Minute), nil
+	case strings.HasPrefix(unit, "d"):
+		return today.AddDate(0, 0, n), nil
+	case strings.HasPrefix(unit, "w"):
+		return today.AddDate(0, 0, 7*n), nil
+	default:
+		return today.AddDate(0, n, 0), nil
+	}
+}
+
+// dueDateNearest returns the date of the year if it is set or the nearest one in the future otherwise
+func dueDateNearest(date func(int, time.Month, int) time.Time, now time.Time, month int, day int, year string) (time.Time, error) {
+	if year != "" {
+		y := atoi(year)
+		if y < 100 {
+			y += 2000
+		}
+		return validDueDate(date, y, month, day)
+	}
+
+	due, err := validDueDate(date, now.Year(), month, day)
+	if err != nil {
+		return due, err
+	}
+
+	// the date is compared without the time so that today's date stays in this year
+	if due.Month() < now.Month() || due.Month() == now.Month() && due.Day() < now.Day() {
+		return validDueDate(date, now.Year()+1, month, day)
+	}
+	return due, nil
+}
+
+// validDueDate builds the date and rejects the overflowing ones like 31.02
+func validDueDate(date func(int, time.Month, int) time.Time, year int, month int, day int) (time.Time, error) {
+	due := date(year, time.Month(month), day)
+	if month < 1 || month > 12 || due.Day() != day {
+		return time.Time{}, fmt.Errorf("wrong date %02d.%02d.%d", day, month, year)
+	}
+	return due, nil
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+// parseUserDueDate parses the due date in the user's time zone and date order
+func parseUserDueDate(c *integram.Context, s string) (time.Time, error) {
+	return parseDueDate(s, time.Now().In(c.User.TzLocation()), dateMonthFirst(c.User.Lang))
+}
+
+// dueDatePreview formats the due date for the user to confirm it
+func dueDatePreview(c *integram.Context, due time.Time) string {
+	due = due.In(c.User.TzLocation())
+	return fmt.Sprintf("%s (%s)", due.Format(dueDatePreviewFormat), decent.Relative(due))
+}
@@ -0,0 +1,129 @@
+package trello
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDueDate(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	// Wednesday
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, loc)
+	date := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		in         string
+		monthFirst bool
+		want       time.Time
+	}{
+		{"today", false, date(2026, 10, 14, 12, 0)},
+		{"Tomorrow 9am", false, date(2026, 10, 15, 9, 0)},
+		{"tomorrow at 9 pm", false, date(2026, 10, 15, 21, 0)},
+		{"9am", false, date(2026, 10, 15, 9, 0)},
+		{"17:00", false, date(2026, 10, 14, 17, 0)},
+		{"noon", false, date(2026, 10, 14, 12, 0)},
+		{"12am", false, date(2026, 10, 15, 0, 0)},
+		{"eod", false, date(2026, 10, 14, 23, 59)},
+		{"eow", false, date(2026, 10, 18, 23, 59)},
+		{"eom", false, date(2026, 10, 31, 23, 59)},
+		{"fri", false, date(2026, 10, 16, 12, 0)},
+		{"wednesday", false, date(2026, 10, 14, 12, 0)},
+		{"wed 9:00", false, date(2026, 10, 21, 9, 0)},
+		{"next friday", false, date(2026, 10, 23, 12, 0)},
+		{"next monday 18:30", false, date(2026, 10, 19, 18, 30)},
+		{"next week", false, date(2026, 10, 19, 12, 0)},
+		{"next month", false, date(2026, 11, 1, 12, 0)},
+		{"in 3 days", false, date(2026, 10, 17, 12, 0)},
+		{"in 3d 10am", false, date(2026, 10, 17, 10, 0)},
+		{"in 2 hours", false, date(2026, 10, 14, 12, 30)},
+		{"in 30m", false, date(2026, 10, 14, 11, 0)},
+		{"in a week", false, date(2026, 10, 21, 12, 0)},
+		{"in 2 months", false, date(2026, 12, 14, 12, 0)},
+		{"25.12 18:00", false, date(2026, 12, 25, 18, 0)},
+		{"14.10", false, date(2026, 10, 14, 12, 0)},
+		{"13.10", false, date(2027, 10, 13, 12, 0)},
+		{"01.02", false, date(2027, 2, 1, 12, 0)},
+		{"25.12.27", false, date(2027, 12, 25, 12, 0)},
+		{"2026-12-25", false, date(2026, 12, 25, 12, 0)},
+		{"2026-12-25T18:00:00Z", false, date(2026, 12, 25, 21, 0)},
+		{"05/06", false, date(2027, 6, 5, 12, 0)},
+		{"05/06", true, date(2027, 5, 6, 12, 0)},
+		{"12/25/2026 5pm", true, date(2026, 12, 25, 17, 0)},
+		{"dec 25", false, date(2026, 12, 25, 12, 0)},
+		{"25th December 2027", false, date(2027, 12, 25, 12, 0)},
+		{"on 1 jan, 8:15", false, date(2027, 1, 1, 8, 15)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDueDate(tt.in, now, tt.monthFirst)
+		if err != nil {
+			t.Errorf("parseDueDate(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDueDate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDueDateErrors(t *testing.T) {
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+
+	tests := []string{
+		"",
+		"someday",
+		"31.02",
+		"2026-13-01",
+		"25:00",
+		"13pm",
+		"in 3 parsecs",
+		"dec 25 next",
+	}
+
+	for _, in := range tests {
+		if got, err := parseDueDate(in, now, false); err == nil {
+			t.Errorf("parseDueDate(%q) = %v, want error", in, got)
+		}
+	}
+}
+
+func TestParseCardSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want cardSpec
+	}{
+		{"Fix login", cardSpec{Name: "Fix login"}},
+		{"Fix login #bug #p1 @alice ^Doing due:fri 17:00 -- description text", cardSpec{
+			Name:    "Fix login",
+			Labels:  []string{"bug", "p1"},
+			Members: []string{"alice"},
+			List:    "Doing",
+			Due:     "fri 17:00",
+			Desc:    "description text",
+		}},
+		{`Deploy ^"In progress" due:"in 3 days" 9am`, cardSpec{Name: "Deploy", List: "In progress", Due: "in 3 days 9am"}},
+		{"Release v2 due:tomorrow 3 times", cardSpec{Name: "Release v2 3 times", Due: "tomorrow"}},
+	}
+
+	for _, tt := range tests {
+		got := parseCardSpec(tt.in)
+		if got.Name != tt.want.Name || got.List != tt.want.List || got.Due != tt.want.Due || got.Desc != tt.want.Desc ||
+			!equalStrings(got.Labels, tt.want.Labels) || !equalStrings(got.Members, tt.want.Members) {
+			t.Errorf("parseCardSpec(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,348 @@
+package trello
+
+import (
+	"errors"
+	"fmt"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+const (
+	idFilterOff = iota
+	idFilterInclude
+	idFilterExclude
+	idFilterChatMembers // only for the members filter: cards assigned to the chat members who authorized the bot
+)
+
+// items per page in the filter pickers
+const filterPickerPageSize = 8
+
+// ChatBoardIDFilter includes or excludes notifications by the set of Trello IDs
+type ChatBoardIDFilter struct {
+	Mode int // idFilterOff, idFilterInclude, idFilterExclude or idFilterChatMembers
+	IDs  []string
+}
+
+// Contains reports whether id is in the filter's set
+func (f ChatBoardIDFilter) Contains(id string) bool {
+	for _, fid := range f.IDs {
+		if fid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Toggle adds id to the set or removes it if it is already there
+func (f *ChatBoardIDFilter) Toggle(id string) {
+	for i, fid := range f.IDs {
+		if fid == id {
+			f.IDs = append(f.IDs[:i], f.IDs[i+1:]...)
+			return
+		}
+	}
+	f.IDs = append(f.IDs, id)
+}
+
+// Active reports whether the filter can drop anything
+func (f ChatBoardIDFilter) Active() bool {
+	return f.Mode == idFilterChatMembers || f.Mode != idFilterOff && len(f.IDs) > 0
+}
+
+// AllowsAny reports whether at least one of ids passes the filter. Empty ids always pass
+func (f ChatBoardIDFilter) AllowsAny(ids ...string) bool {
+	if !f.Active() {
+		return true
+	}
+
+	any := false
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		any = true
+		if f.Contains(id) == (f.Mode == idFilterInclude) {
+			return true
+		}
+	}
+	return !any
+}
+
+// AllowsSet reports whether the whole set of ids passes the filter:
+// at least one of ids must be included or none of them excluded
+func (f ChatBoardIDFilter) AllowsSet(ids []string) bool {
+	if !f.Active() {
+		return true
+	}
+
+	for _, id := range ids {
+		if f.Contains(id) {
+			return f.Mode == idFilterInclude
+		}
+	}
+	return f.Mode == idFilterExclude
+}
+
+func (f ChatBoardIDFilter) modeButtonText(items string) string {
+	switch f.Mode {
+	case idFilterChatMembers:
+		return "🔎 Mode: only assigned to the chat members"
+	case idFilterInclude:
+		return "🔎 Mode: only selected " + items
+	case idFilterExclude:
+		return "🔎 Mode: all except selected " + items
+	default:
+		return "🔎 Mode: all " + items
+	}
+}
+
+// allowsCard checks the card-scoped filters of the chat's board.
+// chatMembers contains Trello IDs of the chat members who authorized the bot
+func (bs ChatBoardSetting) allowsCard(card *t.Card, wh *webhook, chatMembers []string) bool {
+	if card == nil || card.Id == "" {
+		return true
+	}
+
+	listID := card.IdList
+	if card.List != nil {
+		listID = card.List.Id
+	}
+	listBeforeID := ""
+	if wh.Action.Data.ListBefore != nil {
+		listBeforeID = wh.Action.Data.ListBefore.ID
+	}
+
+	if !bs.Lists.AllowsAny(listID, listBeforeID) {
+		return false
+	}
+
+	// label or member that is being added/removed by this action is taken into account
+	var labelIDs []string
+	for _, label := range card.Labels {
+		labelIDs = append(labelIDs, label.Id)
+	}
+	if wh.Action.Data.Label != nil {
+		labelIDs = append(labelIDs, wh.Action.Data.Label.ID)
+	}
+
+	if !bs.Labels.AllowsSet(labelIDs) {
+		return false
+	}
+
+	memberIDs := append([]string{}, card.IdMembers...)
+	for _, member := range card.Members {
+		memberIDs = append(memberIDs, member.Id)
+	}
+	if wh.Action.Member != nil {
+		memberIDs = append(memberIDs, wh.Action.Member.Id)
+	}
+
+	if bs.Members.Mode == idFilterChatMembers {
+		for _, id := range memberIDs {
+			if integram.SliceContainsString(chatMembers, id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return bs.Members.AllowsSet(memberIDs)
+}
+
+// rememberChatMember adds the authorized user's Trello ID to the chat's members
+func rememberChatMember(c *integram.Context) {
+	if !c.User.OAuthValid() {
+		return
+	}
+
+	me, err := me(c, api(c))
+	if err != nil {
+		return
+	}
+
+	cs := chatSettings(c)
+	if integram.SliceContainsString(cs.TrelloMembers, me.Id) {
+		return
+	}
+
+	err = c.Chat.SaveSetting("TrelloMembers", append(cs.TrelloMembers, me.Id))
+	if err != nil {
+		c.Log().WithError(err).Error("Can't save the chat members")
+	}
+}
+
+// pickerKeyboard renders one page of items with the navigation buttons
+func pickerKeyboard(items integram.Buttons, page int, header ...integram.Button) integram.Keyboard {
+	keyboard := integram.Keyboard{}
+	if len(header) > 0 {
+		keyboard.AddRows(integram.Buttons(header))
+	}
+
+	from := page * filterPickerPageSize
+	if from >= len(items) {
+		from = 0
+	}
+	to := from + filterPickerPageSize
+	if to > len(items) {
+		to = len(items)
+	}
+
+	for i := from; i < to; i += 2 {
+		if i+1 < to {
+			keyboard.AddRows(items[i : i+2])
+		} else {
+			keyboard.AddRows(items[i : i+1])
+		}
+	}
+
+	nav := integram.Buttons{}
+	if from > 0 {
+		nav.Append("prev", "« Previous")
+	}
+	if to < len(items) {
+		nav.Append("next", "Next »")
+	}
+	nav.Append("back", "← Back")
+	keyboard.AddRows(nav)
+
+	return keyboard
+}
+
+// boardIDFilter returns the board setting's filter of the kind: "lists", "labels" or "members"
+func boardIDFilter(bs *ChatBoardSetting, kind string) *ChatBoardIDFilter {
+	switch kind {
+	case "labels":
+		return &bs.Labels
+	case "members":
+		return &bs.Members
+	default:
+		return &bs.Lists
+	}
+}
+
+func boardIDFilterButtons(c *integram.Context, boardID string, kind string, filter *ChatBoardIDFilter) (integram.Buttons, error) {
+	api := api(c)
+	buttons := integram.Buttons{}
+
+	add := func(id string, text string) {
+		if filter.Contains(id) {
+			text = markSign + text
+		}
+		buttons.Append(id, text)
+	}
+
+	switch kind {
+	case "labels":
+		labels, err := labelsByBoardID(c, api, boardID)
+		if err != nil {
+			return nil, err
+		}
+		for _, label := range labels {
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			add(label.Id, colorEmoji(label.Color)+" "+name)
+		}
+	case "members":
+		members, err := membersByBoardID(c, api, boardID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			add(member.Id, "@"+member.Username)
+		}
+	default:
+		lists, err := listsByBoardID(c, api, boardID)
+		if err != nil {
+			return nil, err
+		}
+		for _, list := range lists {
+			add(list.Id, list.Name)
+		}
+	}
+	return buttons, nil
+}
+
+func sendBoardIDFilterKeyboard(c *integram.Context, boardID string, kind string, page int) error {
+	cs := chatSettings(c)
+	bs, ok := cs.Boards[boardID]
+	if !ok {
+		return errors.New("Can't find board settings on user")
+	}
+	filter := boardIDFilter(&bs, kind)
+
+	buttons, err := boardIDFilterButtons(c, boardID, kind, filter)
+	if err != nil {
+		return err
+	}
+
+	return c.NewMessage().
+		SetText(fmt.Sprintf("%v select the %s to get notifications about on \"%v\" board", c.User.Mention(), kind, bs.Name)).
+		SetKeyboard(pickerKeyboard(buttons, page, integram.Button{Data: "mode", Text: filter.modeButtonText(kind)}), true).
+		SetSilent(true).
+		SetReplyToMsgID(c.Message.MsgID).
+		SetReplyAction(boardIDFilterButtonPressed, boardID, kind, page).
+		Send()
+}
+
+func boardIDFilterButtonPressed(c *integram.Context, boardID string, kind string, page int) error {
+	answer, _ := c.KeyboardAnswer()
+
+	cs := chatSettings(c)
+	bs, ok := cs.Boards[boardID]
+	if !ok {
+		return errors.New("Can't find board settings on user")
+	}
+	filter := boardIDFilter(&bs, kind)
+
+	switch answer {
+	case "back":
+		return sendBoardFiltersKeyboard(c, boardID)
+	case "prev":
+		if page > 0 {
+			page--
+		}
+	case "next":
+		page++
+	case "mode":
+		modes := 3
+		if kind == "members" {
+			modes = 4
+		}
+		filter.Mode = (filter.Mode + 1) % modes
+	case "":
+		return nil
+	default:
+		buttons, err := boardIDFilterButtons(c, boardID, kind, filter)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, button := range buttons {
+			if button.Data == answer {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("wrong %s ID %s", kind, answer)
+		}
+
+		filter.Toggle(answer)
+		if filter.Mode == idFilterOff || filter.Mode == idFilterChatMembers {
+			filter.Mode = idFilterInclude
+		}
+	}
+
+	if answer != "prev" && answer != "next" {
+		cs.Boards[boardID] = bs
+		err := c.Chat.SaveSettings(cs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return sendBoardIDFilterKeyboard(c, boardID, kind, page)
+}
@@ -0,0 +1,152 @@
+package trello
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/requilence/integram"
+	tg "github.com/requilence/telegram-bot-api"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	messageCardNameLength = 100           // max length of the card name taken from the message text
+	chatMessageTTL        = time.Hour * 6 // how long the files and forwards are remembered for /card
+)
+
+// chatMessage is the message seen by the bot. Messages with files or forwarded ones are remembered for some time
+// to create the card from them with /card, the replied message has neither the files nor the forward's author
+type chatMessage struct {
+	MsgID   int
+	Author  string
+	Forward string // author of the original message if it was forwarded
+	Text    string // caption of the file. The replied message's text is used otherwise
+	Date    time.Time
+	File    tg.Document // FileID is empty if there is no file
+}
+
+// rememberChatMessage caches the file or the forward's author of the message so /card sent in reply can use them.
+// Only the chats with the integrated boards are cached
+func rememberChatMessage(c *integram.Context) {
+	msg := c.Message
+	if msg == nil || msg.ForwardFrom == nil && msg.ForwardFromChat == nil && msg.Document == nil && msg.Photo == nil {
+		return
+	}
+
+	integrated := false
+	for _, bs := range chatSettings(c).Boards {
+		integrated = integrated || bs.Enabled
+	}
+	if !integrated {
+		return
+	}
+
+	cm := chatMessage{MsgID: msg.MsgID, Author: c.User.String(), Text: msg.Caption, Date: msg.Date}
+	if msg.ForwardFrom != nil {
+		cm.Forward = msg.ForwardFrom.String()
+	} else if msg.ForwardFromChat != nil {
+		cm.Forward = msg.ForwardFromChat.Title
+	}
+
+	if msg.Document != nil {
+		cm.File = *msg.Document
+	} else if msg.Photo != nil && len(*msg.Photo) > 0 {
+		// the last size is the largest one
+		photo := (*msg.Photo)[len(*msg.Photo)-1]
+		cm.File = tg.Document{MimeType: "image/jpeg", FileID: photo.FileID, FileSize: photo.FileSize, FileName: fmt.Sprintf("photo_%d.jpg", msg.MsgID)}
+	}
+
+	c.Chat.SetCache(fmt.Sprintf("msg_%d", msg.MsgID), cm, chatMessageTTL)
+}
+
+// messageLink returns the t.me link to the message. Only supergroups and channels have them
+func messageLink(chat integram.Chat, msgID int) string {
+	if chat.UserName != "" && chat.IsGroup() {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, msgID)
+	}
+
+	id := strconv.FormatInt(chat.ID, 10)
+	if strings.HasPrefix(id, "-100") {
+		return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), msgID)
+	}
+	return ""
+}
+
+// messageCardSpec makes the card from the message: the first line of the text is the name unless name is set,
+// the text with the attribution is the description
+func messageCardSpec(c *integram.Context, cm chatMessage, name string) cardSpec {
+	text := strings.TrimSpace(cm.Text)
+
+	if name == "" {
+		name = strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+		if r := []rune(name); len(r) > messageCardNameLength {
+			name = string(r[:messageCardNameLength-1]) + "…"
+		}
+	}
+	if name == "" && cm.File.FileName != "" {
+		name = cm.File.FileName
+	}
+	if name == "" {
+		name = "Message from " + cm.Author
+	}
+
+	author := cm.Author
+	if cm.Forward != "" {
+		author = cm.Forward + " (forwarded by " + cm.Author + ")"
+	}
+
+	attribution := "— " + author
+	if c.Chat.IsGroup() && c.Chat.Title != "" {
+		attribution += " in " + c.Chat.Title
+	}
+	if !cm.Date.IsZero() {
+		attribution += ", " + cm.Date.In(c.User.TzLocation()).Format("02 Jan 2006 15:04")
+	}
+	if link := messageLink(c.Chat, cm.MsgID); link != "" {
+		attribution += "\n" + link
+	}
+
+	desc := attribution
+	if text != "" {
+		desc = text + "\n\n" + attribution
+	}
+
+	return cardSpec{Name: name, Desc: desc}
+}
+
+// cardFromMessageCommand creates the card from the message that /card replies to. param overrides the card name
+func cardFromMessageCommand(c *integram.Context, param string) error {
+	if c.Message.ReplyToMessage == nil {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("Send /card in reply to the message to create the card from it. The files and forwarded messages are remembered for 6 hours in the chats with the integrated boards").
+			Send()
+	}
+
+	rm := c.Message.ReplyToMessage
+	var cm chatMessage
+	if c.Chat.Cache(fmt.Sprintf("msg_%d", rm.MsgID), &cm) {
+		if cm.Text == "" {
+			cm.Text = rm.Text
+		}
+	} else {
+		if rm.Text == "" {
+			return c.NewMessage().
+				SetReplyToMsgID(c.Message.MsgID).
+				SetText("Sorry, I can't see the file of this message, I remember the files for 6 hours and only in the chats with the integrated boards. Forward it to me and reply /card to the forwarded one").
+				Send()
+		}
+
+		cm = chatMessage{MsgID: rm.MsgID, Text: rm.Text, Date: rm.Date, Author: "unknown"}
+		if rm.FromID == c.User.ID {
+			cm.Author = c.User.String()
+		} else if author, err := c.FindUser(bson.M{"_id": rm.FromID}); err == nil {
+			// the bot knows the users it has seen
+			cm.Author = author.String()
+		}
+	}
+
+	return createCardInDefaultList(c, messageCardSpec(c, cm, strings.TrimSpace(param)), cm)
+}
@@ -0,0 +1,113 @@
+package trello
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+// moveToBoardKeyboard lets to choose the board to move the card to. Keyboard's state is "move_board"
+func moveToBoardKeyboard(c *integram.Context, api *t.Client, card *t.Card, page int) (integram.InlineKeyboard, error) {
+	boards, err := boards(c, api)
+	if err != nil {
+		return integram.InlineKeyboard{}, err
+	}
+
+	buttons := integram.InlineButtons{}
+	for _, board := range boards {
+		if card.Board == nil || board.Id != card.Board.Id {
+			buttons.Append(board.Id, board.Name)
+		}
+	}
+
+	kb := inlinePage(buttons, page, "page", "move_board", integram.InlineButton{Data: "move", Text: "← Back"})
+	kb.FixedWidth = true
+	return kb, nil
+}
+
+// moveToBoardListsKeyboard lets to choose the list on the destination board. Keyboard's state is "mb_" + board ID
+func moveToBoardListsKeyboard(c *integram.Context, api *t.Client, boardID string) (integram.InlineKeyboard, error) {
+	lists, err := listsByBoardID(c, api, boardID)
+	if err != nil {
+		return integram.InlineKeyboard{}, err
+	}
+
+	buttons := integram.InlineButtons{}
+	for _, list := range lists {
+		buttons.Append(list.Id, list.Name)
+	}
+	buttons.Append("board", "← Back")
+
+	kb := buttons.Markup(1, "mb_"+boardID)
+	kb.FixedWidth = true
+	return kb, nil
+}
+
+// moveToBoardButtonPressed handles the board and list selection when the card is moved to another board
+func moveToBoardButtonPressed(c *integram.Context, api *t.Client, card *t.Card) error {
+	state := c.Callback.Message.InlineKeyboardMarkup.State
+
+	var kb integram.InlineKeyboard
+	var err error
+	switch {
+	case c.Callback.Data == "board":
+		kb, err = moveToBoardKeyboard(c, api, card, 0)
+	case state == "move_board" && c.Callback.Data == "page":
+		kb, err = moveToBoardKeyboard(c, api, card, c.Callback.State)
+	case state == "move_board":
+		kb, err = moveToBoardListsKeyboard(c, api, c.Callback.Data)
+	default:
+		return moveCardToBoard(c, api, strings.TrimPrefix(state, "mb_"), c.Callback.Data, card)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery("", false)
+	return c.EditPressedInlineKeyboard(kb)
+}
+
+// moveCardToBoard moves the card to the list of another board and updates the cache and all the card messages.
+// The card is fetched again because Trello changes its labels and members to fit the destination board
+func moveCardToBoard(c *integram.Context, api *t.Client, boardID string, listID string, card *t.Card) error {
+	_, err := api.Request("PUT", "cards/"+card.Id, nil, url.Values{"idBoard": {boardID}, "idList": {listID}})
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	moved, err := api.Card(card.Id)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+	// keep the creator, it is known only from the createCard action
+	if moved.MemberCreator == nil {
+		moved.MemberCreator = card.MemberCreator
+	}
+
+	err = c.SetServiceCache("card_"+moved.Id, moved, time.Hour*24*100)
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery(fmt.Sprintf("You moved card \"%s\" to %s", moved.Name, cardPath(moved)), false)
+
+	text, kb := cardText(c, moved), cardInlineKeyboard(moved, false)
+	err = c.EditPressedMessageTextAndInlineKeyboard(text, kb)
+	if err != nil {
+		return err
+	}
+	// messages opened in the other menus keep their keyboards
+	c.EditMessagesWithEventID("card_"+moved.Id, "actions", text, kb)
+	c.EditMessagesWithEventID("card_"+moved.Id, "created", text, cardCreatedKeyboard())
+	return nil
+}
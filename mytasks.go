@@ -0,0 +1,268 @@
+package trello
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/now"
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/decent"
+	"github.com/requilence/integram"
+	tg "github.com/requilence/telegram-bot-api"
+)
+
+const (
+	myTasksTextLimit    = 4000 // max length of the rendered text to fit the Telegram message limit
+	myTasksGroupReserve = 64   // room kept for the group's title and "… and N more"
+)
+
+// myTasksMessage is the pinned /mytasks message of the user. Stored in the service cache by Trello member ID
+type myTasksMessage struct {
+	UserID  int64
+	EventID string
+}
+
+type byDue []*t.Card
+
+func (a byDue) Len() int {
+	return len(a)
+}
+
+func (a byDue) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a byDue) Less(i, j int) bool {
+	if a[i].Due == nil || a[j].Due == nil {
+		return a[j].Due == nil && a[i].Due != nil
+	}
+	return a[i].Due.Before(*a[j].Due)
+}
+
+func myTasksText(c *integram.Context) (string, error) {
+	api := api(c)
+
+	var cards []*t.Card
+	b, err := api.Request("GET", "members/me/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,due,dueComplete,idBoard,idList,shortUrl"}})
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal(b, &cards)
+	if err != nil {
+		return "", err
+	}
+	sort.Sort(byDue(cards))
+
+	boards, err := boards(c, api)
+	if err != nil {
+		return "", err
+	}
+
+	loc := c.User.TzLocation()
+	tm := now.New(time.Now().In(loc))
+	groups := map[string][]string{}
+	titles := []string{"🔥 Overdue", "📅 Today", "🗓 This week", "⏳ Later", "📭 No due date"}
+
+	for _, card := range cards {
+		if card.DueComplete {
+			continue
+		}
+
+		path := ""
+		if board := boardsFilterByID(boards, card.IdBoard); board != nil {
+			path = board.Name
+			if lists, err := listsByBoardID(c, api, card.IdBoard); err == nil {
+				if list := listsFilterByID(lists, card.IdList); list != nil {
+					path = list.Name + " • " + path
+				}
+			}
+		}
+
+		line := m.URL(card.Name, card.URL()) + " " + m.Italic(path)
+
+		var group string
+		switch {
+		case card.Due == nil || card.Due.IsZero():
+			group = titles[4]
+		case card.Due.Before(time.Now()):
+			group = titles[0]
+		case card.Due.Before(tm.EndOfDay()):
+			group = titles[1]
+		case card.Due.Before(tm.EndOfWeek()):
+			group = titles[2]
+		default:
+			group = titles[3]
+		}
+		if group != titles[4] {
+			line += ", " + decent.Relative(card.Due.In(loc))
+		}
+		groups[group] = append(groups[group], line)
+	}
+
+	text := fmt.Sprintf("📋 %s (updated at %s)\n", m.Bold("My tasks"), time.Now().In(loc).Format("15:04"))
+	if len(groups) == 0 {
+		return text + "\nThere are no open cards assigned to you", nil
+	}
+
+	var shown []string
+	for _, title := range titles {
+		if len(groups[title]) > 0 {
+			shown = append(shown, title)
+		}
+	}
+
+	for i, title := range shown {
+		lines := groups[title]
+		// keep the room for this group's counter and the next groups
+		reserve := (len(shown) - i) * myTasksGroupReserve
+
+		text += "\n" + m.Bold(title)
+		n := 0
+		for _, line := range lines {
+			if utf8.RuneCountInString(text)+utf8.RuneCountInString(line)+reserve > myTasksTextLimit {
+				break
+			}
+			text += "\n• " + line
+			n++
+		}
+		if n < len(lines) {
+			text += fmt.Sprintf("\n… and %d more", len(lines)-n)
+		}
+		text += "\n"
+	}
+	return text, nil
+}
+
+func myTasksCommand(c *integram.Context) error {
+	if c.Chat.IsGroup() {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("Your tasks are available in the private chat").
+			SetInlineKeyboard(integram.InlineButton{Text: "Show my tasks", URL: c.Bot().PMURL("mytasks")}).
+			Send()
+	}
+
+	if !c.User.OAuthValid() {
+		return c.NewMessage().
+			SetTextFmt("You need to auth me to see your tasks. Open this link to authorize me: %s", oauthRedirectURL(c)).
+			Send()
+	}
+
+	me, err := me(c, api(c))
+	if err != nil {
+		return err
+	}
+
+	text, err := myTasksText(c)
+	if err != nil {
+		return err
+	}
+
+	// every /mytasks message has its own event ID, so only the latest one is pinned and refreshed
+	eventID := "mytasks_" + me.Id + "_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	err = c.NewMessage().
+		AddEventID(eventID).
+		EnableHTML().
+		DisableWebPreview().
+		SetText(text).
+		Send()
+	if err != nil {
+		return err
+	}
+
+	// the message is sent by the queue, so it is pinned once it appears in the messages
+	_, err = c.Service().SheduleJob(pinMyTasks, 0, time.Now().Add(time.Second*3), c, eventID)
+	if err != nil {
+		c.Log().WithError(err).Error("Can't schedule the tasks message pin")
+	}
+
+	return c.SetServiceCache("mytasks_"+me.Id, myTasksMessage{UserID: c.User.ID, EventID: eventID}, time.Hour*24*365)
+}
+
+// pinMyTasks pins the sent /mytasks message, returns error to retry if the message isn't sent yet
+func pinMyTasks(c *integram.Context, eventID string) error {
+	msg, err := c.FindMessageByEventID(eventID)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return errors.New("the tasks message is not sent yet")
+	}
+
+	// integram has no pin method
+	_, err = c.Bot().API.PinChatMessage(tg.PinChatMessageConfig{ChatID: c.Chat.ID, MessageID: msg.MsgID, DisableNotification: true})
+	if err != nil {
+		c.Log().WithError(err).Error("Can't pin the tasks message")
+	}
+	return nil
+}
+
+// refreshMyTasks edits the pinned /mytasks message of the user
+func refreshMyTasks(c *integram.Context, memberID string) error {
+	var tm myTasksMessage
+	if !c.ServiceCache("mytasks_"+memberID, &tm) || tm.EventID == "" {
+		return nil
+	}
+
+	text, err := myTasksText(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.EditMessagesTextWithEventID(tm.EventID, text)
+	if err != nil {
+		c.Log().WithError(err).Error("Can't update the tasks message")
+	}
+	return nil
+}
+
+// scheduleMyTasksRefresh refreshes /mytasks messages of the card's members when action changes the assignment, due date or list of the card
+func scheduleMyTasksRefresh(c *integram.Context, wh *webhook, card *t.Card) {
+	switch wh.Action.Type {
+	case "addMemberToCard", "removeMemberFromCard", "deleteCard", "moveCardToBoard", "moveCardFromBoard":
+	case "updateCard":
+		old := wh.Action.Data.Old
+		if old == nil || !(old.IDList != "" || old.Name != "" || old.has("closed") || old.has("due") || old.has("dueComplete")) {
+			return
+		}
+	default:
+		return
+	}
+
+	memberIDs := append([]string{}, card.IdMembers...)
+	for _, member := range card.Members {
+		memberIDs = append(memberIDs, member.Id)
+	}
+	if wh.Action.Member != nil {
+		memberIDs = append(memberIDs, wh.Action.Member.Id)
+	}
+
+	for _, memberID := range memberIDs {
+		var tm myTasksMessage
+		if !c.ServiceCache("mytasks_"+memberID, &tm) {
+			continue
+		}
+
+		// several actions in a row produce the single refresh
+		if pending := false; c.ServiceCache("mytasks_pending_"+memberID, &pending) && pending {
+			continue
+		}
+		c.SetServiceCache("mytasks_pending_"+memberID, true, time.Second*10)
+
+		// refresh is done on behalf of the tasks owner
+		uc := &integram.Context{ServiceName: c.ServiceName, ServiceBaseURL: c.ServiceBaseURL, User: integram.User{ID: tm.UserID}, Chat: integram.Chat{ID: tm.UserID}}
+		_, err := c.Service().SheduleJob(refreshMyTasks, 0, time.Now().Add(time.Second*10), uc, memberID)
+		if err != nil {
+			c.Log().WithError(err).Error("Can't schedule the tasks refresh")
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package trello
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+const (
+	boardOverviewTopCards = 3 // cards shown under every list in the overview
+	inlinePageSize        = 8 // items per page in the paginated inline keyboards
+)
+
+type byPos []*t.Card
+
+func (a byPos) Len() int {
+	return len(a)
+}
+
+func (a byPos) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a byPos) Less(i, j int) bool {
+	return a[i].Pos < a[j].Pos
+}
+
+// inlinePage renders one page of items in a column with the navigation row.
+// Navigation buttons have navData as data and the number of the page to open as state
+func inlinePage(items integram.InlineButtons, page int, navData string, state string, extra ...integram.InlineButton) integram.InlineKeyboard {
+	from := page * inlinePageSize
+	if from >= len(items) {
+		from = 0
+	}
+	to := from + inlinePageSize
+	if to > len(items) {
+		to = len(items)
+	}
+
+	kb := integram.InlineKeyboard{State: state}
+	for _, item := range items[from:to] {
+		kb.AppendRows(integram.InlineButtons{item})
+	}
+
+	nav := integram.InlineButtons{}
+	if from > 0 {
+		nav.AppendWithState(from/inlinePageSize-1, navData, "« Previous")
+	}
+	if to < len(items) {
+		nav.AppendWithState(from/inlinePageSize+1, navData, "Next »")
+	}
+	nav = append(nav, extra...)
+	if len(nav) > 0 {
+		kb.AppendRows(nav)
+	}
+	return kb
+}
+
+// cardsByList returns the open cards of the board grouped by list ID and sorted by position
+func cardsByList(c *integram.Context, api *t.Client, boardID string) (map[string][]*t.Card, error) {
+	cards, err := boardOpenCards(c, api, boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byPos(cards))
+	res := map[string][]*t.Card{}
+	for _, card := range cards {
+		res[card.IdList] = append(res[card.IdList], card)
+	}
+	return res, nil
+}
+
+func isOverdue(card *t.Card) bool {
+	return card.Due != nil && !card.Due.IsZero() && card.Due.Before(time.Now()) && !card.DueComplete
+}
+
+func boardOverview(c *integram.Context, boardID string) (string, integram.InlineKeyboard, error) {
+	api := api(c)
+
+	// fresh lists are fetched to take into account the recently created ones
+	lists, _, _, err := getBoardData(c, api, boardID)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+	byList, err := cardsByList(c, api, boardID)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+
+	name, integrated := boardName(c, api, boardID)
+	text := "📋 " + m.Bold(name) + "\n"
+	buttons := integram.InlineButtons{}
+	// lists that don't fit the message limit are only counted, their buttons are still shown
+	hidden := 0
+	for _, list := range lists {
+		cards := byList[list.Id]
+		buttons.Append(list.Id, fmt.Sprintf("%s (%d)", list.Name, len(cards)))
+		if hidden > 0 {
+			hidden++
+			continue
+		}
+
+		overdue := 0
+		for _, card := range cards {
+			if isOverdue(card) {
+				overdue++
+			}
+		}
+
+		block := fmt.Sprintf("\n📁 %s – %s", m.Bold(list.Name), pluralize(len(cards), "card"))
+		if overdue > 0 {
+			block += fmt.Sprintf(", 🔥 %d overdue", overdue)
+		}
+		block += "\n"
+
+		for i := 0; i < len(cards) && i < boardOverviewTopCards; i++ {
+			block += "  • " + m.URL(cards[i].Name, cards[i].URL()) + "\n"
+		}
+		if len(cards) > boardOverviewTopCards {
+			block += fmt.Sprintf("  … and %d more\n", len(cards)-boardOverviewTopCards)
+		}
+
+		if utf8.RuneCountInString(text)+utf8.RuneCountInString(block)+myTasksGroupReserve > myTasksTextLimit {
+			hidden++
+			continue
+		}
+		text += block
+	}
+	if hidden > 0 {
+		text += fmt.Sprintf("\n…and %s more", pluralize(hidden, "list"))
+	}
+
+	kb := buttons.Markup(2, "lists")
+	if !integrated {
+		kb.AppendRows(integram.InlineButtons{{Data: "integrate", Text: "➕ Integrate here"}})
+	}
+	return text, kb, nil
+}
+
+// boardName returns the name of the board and whether it is integrated into the chat.
+// Names of the boards that are not integrated are fetched from Trello
+func boardName(c *integram.Context, api *t.Client, boardID string) (name string, integrated bool) {
+	if board, exists := chatSettings(c).Boards[boardID]; exists {
+		return board.Name, true
+	}
+
+	board, err := api.Board(boardID)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		c.Log().WithError(err).WithField("board", boardID).Error("can't get the board")
+		return "", false
+	}
+	return board.Name, false
+}
+
+func boardListCards(c *integram.Context, boardID string, listID string, page int) (string, integram.InlineKeyboard, error) {
+	api := api(c)
+
+	lists, err := listsByBoardID(c, api, boardID)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+	list := listsFilterByID(lists, listID)
+	if list == nil {
+		return "", integram.InlineKeyboard{}, fmt.Errorf("list %s not found", listID)
+	}
+
+	byList, err := cardsByList(c, api, boardID)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+	cards := byList[listID]
+
+	buttons := integram.InlineButtons{}
+	for _, card := range cards {
+		text := card.Name
+		if isOverdue(card) {
+			text = "🔥 " + text
+		}
+		buttons.Append("c_"+card.Id, text)
+	}
+
+	name, _ := boardName(c, api, boardID)
+	text := fmt.Sprintf("📁 %s • %s\n%s", m.Bold(list.Name), name, pluralize(len(cards), "card"))
+	pages := (len(cards) + inlinePageSize - 1) / inlinePageSize
+	if pages > 1 {
+		text += fmt.Sprintf(", page %d of %d", page+1, pages)
+	}
+
+	return text, inlinePage(buttons, page, listID, "cards", integram.InlineButton{Data: "back", Text: "↑ Back"}), nil
+}
+
+func boardCommand(c *integram.Context, param string) error {
+	if !c.User.OAuthValid() {
+		kb := integram.InlineKeyboard{}
+		kb.AddPMSwitchButton(c.Bot(), "👉  Tap me to auth", "auth")
+
+		return c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("You need to auth me to see the boards").SetInlineKeyboard(kb).Send()
+	}
+
+	cs := chatSettings(c)
+	boardIDs := sortedBoardIDs(cs)
+	if len(boardIDs) == 0 {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("There are no boards integrated into this chat. Use /connect first").
+			Send()
+	}
+
+	param = strings.ToLower(strings.TrimSpace(param))
+	if param != "" {
+		var matched []string
+		for _, id := range boardIDs {
+			if strings.Contains(strings.ToLower(cs.Boards[id].Name), param) {
+				matched = append(matched, id)
+			}
+		}
+		if len(matched) > 0 {
+			boardIDs = matched
+		}
+	}
+
+	msg := c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		EnableHTML().
+		DisableWebPreview()
+
+	if len(boardIDs) > 1 {
+		buttons := integram.InlineButtons{}
+		for _, id := range boardIDs {
+			buttons.Append(id, cs.Boards[id].Name)
+		}
+		return msg.SetText("Select the board").
+			SetInlineKeyboard(buttons.Markup(1, "boards")).
+			SetCallbackAction(boardOverviewButtonPressed, "").
+			Send()
+	}
+
+	text, kb, err := boardOverview(c, boardIDs[0])
+	if err != nil {
+		return err
+	}
+
+	return msg.SetText(text).
+		SetInlineKeyboard(kb).
+		SetCallbackAction(boardOverviewButtonPressed, boardIDs[0]).
+		Send()
+}
+
+func boardOverviewButtonPressed(c *integram.Context, boardID string) error {
+	if !c.User.OAuthValid() {
+		return c.AnswerCallbackQuery("You need to authorize me\nUse the /start command in the private chat with me", true)
+	}
+
+	data := c.Callback.Data
+
+	switch {
+	case c.Callback.Message.InlineKeyboardMarkup.State == "boards":
+		// board selector is replaced with the overview message bound to the selected board
+		text, kb, err := boardOverview(c, data)
+		if err != nil {
+			return err
+		}
+		c.AnswerCallbackQuery("", false)
+		c.DeleteMessage(c.Callback.Message)
+
+		return c.NewMessage().
+			SetText(text).
+			EnableHTML().
+			DisableWebPreview().
+			SetInlineKeyboard(kb).
+			SetCallbackAction(boardOverviewButtonPressed, data).
+			Send()
+	case data == "back":
+		text, kb, err := boardOverview(c, boardID)
+		if err != nil {
+			return err
+		}
+		return c.EditPressedMessageTextAndInlineKeyboard(text, kb)
+	case strings.HasPrefix(data, "c_"):
+		return sendCard(c, strings.TrimPrefix(data, "c_"))
+	case data == "integrate":
+		board, err := api(c).Board(boardID)
+		if err != nil {
+			return err
+		}
+		c.AnswerCallbackQuery("", false)
+		return scheduleSubscribeIfBoardNotAlreadyExists(c, board, c.Chat.ID)
+	default:
+		text, kb, err := boardListCards(c, boardID, data, c.Callback.State)
+		if err != nil {
+			return err
+		}
+		return c.EditPressedMessageTextAndInlineKeyboard(text, kb)
+	}
+}
+
+// sendCard posts the card with its action keyboard into the current chat
+func sendCard(c *integram.Context, cardID string) error {
+	card, err := getCard(c, api(c), cardID)
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery("", false)
+
+	return c.NewMessage().
+		SetText(cardText(c, card)).
+		AddEventID("card_"+card.Id).
+		EnableHTML().
+		SetReplyAction(cardReplied, card.Id).
+		SetInlineKeyboard(cardInlineKeyboard(card, false)).
+		SetCallbackAction(inlineCardButtonPressed, card.Id).
+		Send()
+}
@@ -0,0 +1,276 @@
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// listCards fetches the open cards of the list sorted by position and caches their order for cardRank
+func listCards(c *integram.Context, api *t.Client, listID string) ([]*t.Card, error) {
+	var cards []*t.Card
+	b, err := api.Request("GET", "lists/"+listID+"/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,pos,idList"}})
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &cards)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byPos(cards))
+	setListOrder(c, listID, cards)
+	return cards, nil
+}
+
+func setListOrder(c *integram.Context, listID string, cards []*t.Card) {
+	ids := make([]string, len(cards))
+	for i, card := range cards {
+		ids[i] = card.Id
+	}
+	err := c.SetServiceCache("list_order_"+listID, ids, time.Hour)
+	if err != nil {
+		c.Log().WithError(err).Error("can't cache the list order")
+	}
+}
+
+// refreshListOrder refetches the order of the lists affected by the card action and updates the ranks of the other cards
+func refreshListOrder(c *integram.Context, a action) {
+	switch a.Type {
+	case "createCard", "copyCard", "deleteCard", "moveCardToBoard", "moveCardFromBoard", "convertToCardFromCheckItem":
+	case "updateCard":
+		// renames, descriptions, due dates and other edits keep the order
+		if a.Data.ListBefore == nil && !a.Data.Old.has("pos") && !a.Data.Old.has("closed") {
+			return
+		}
+	default:
+		return
+	}
+
+	api := api(c)
+	for _, listID := range []string{a.Data.List.ID, listBeforeID(a)} {
+		if listID == "" {
+			continue
+		}
+		var prev []string
+		c.ServiceCache("list_order_"+listID, &prev)
+
+		cards, err := listCards(c, api, listID)
+		if err != nil {
+			c.Log().WithError(err).WithField("list", listID).Error("can't refresh the list order")
+			// nil value removes the outdated order
+			c.SetServiceCache("list_order_"+listID, nil, 0)
+			continue
+		}
+		// the action's card messages are updated by the webhook handler
+		refreshCardRanks(c, prev, cards, a.Data.Card.Id)
+	}
+}
+
+// refreshCardRanks updates the messages of the list's cards which rank changed since the prev order, except the skipped card.
+// Nothing is updated if the previous order is unknown
+func refreshCardRanks(c *integram.Context, prev []string, cards []*t.Card, skipID string) {
+	if len(prev) == 0 {
+		return
+	}
+
+	for i, sibling := range cards {
+		if sibling.Id == skipID || len(prev) == len(cards) && prev[i] == sibling.Id {
+			continue
+		}
+		card := &t.Card{}
+		if !c.ServiceCache("card_"+sibling.Id, card) {
+			continue
+		}
+		text := cardText(c, card)
+		c.EditMessagesWithEventID("card_"+card.Id, "actions", text, cardInlineKeyboard(card, false))
+		c.EditMessagesWithEventID("card_"+card.Id, "created", text, cardCreatedKeyboard())
+	}
+}
+
+func listBeforeID(a action) string {
+	if a.Data.ListBefore == nil {
+		return ""
+	}
+	return a.Data.ListBefore.ID
+}
+
+// cardRank returns the 1-based position of the card in its list and the number of cards there.
+// The list order is fetched if it isn't cached, rank is 0 if it can't be fetched
+func cardRank(c *integram.Context, card *t.Card) (rank int, total int) {
+	if card.List == nil || card.List.Id == "" || card.Closed {
+		return 0, 0
+	}
+
+	var ids []string
+	if !c.ServiceCache("list_order_"+card.List.Id, &ids) {
+		cards, err := listCards(c, api(c), card.List.Id)
+		if err != nil {
+			c.Log().WithError(err).WithField("list", card.List.Id).Debug("can't fetch the list order")
+			return 0, 0
+		}
+		for _, sibling := range cards {
+			ids = append(ids, sibling.Id)
+		}
+	}
+	for i, id := range ids {
+		if id == card.Id {
+			return i + 1, len(ids)
+		}
+	}
+	return 0, 0
+}
+
+// cardInsertPos returns the Trello position to put the card before others[k], others are the sorted siblings
+func cardInsertPos(others []*t.Card, k int) string {
+	switch {
+	case k <= 0:
+		return "top"
+	case k >= len(others):
+		return "bottom"
+	}
+	return strconv.FormatFloat((others[k-1].Pos+others[k].Pos)/2, 'f', -1, 64)
+}
+
+func cardPositionKeyboard(backData string) integram.InlineKeyboard {
+	buttons := integram.InlineButtons{}
+	buttons.Append("top", "⏫ Top")
+	buttons.Append("up", "🔼 Up")
+	buttons.Append("down", "🔽 Down")
+	buttons.Append("bottom", "⏬ Bottom")
+	buttons.Append("after", "📍 After card…")
+	buttons.Append(backData, "← Back")
+	return buttons.Markup(4, "position")
+}
+
+// cardAfterKeyboard lets to choose the card to put this one after. Keyboard's state is "pos_after"
+func cardAfterKeyboard(card *t.Card, siblings []*t.Card, page int) integram.InlineKeyboard {
+	buttons := integram.InlineButtons{}
+	for _, sibling := range siblings {
+		if sibling.Id != card.Id {
+			buttons.Append(sibling.Id, sibling.Name)
+		}
+	}
+
+	kb := inlinePage(buttons, page, "page", "pos_after", integram.InlineButton{Data: "position", Text: "← Back"})
+	kb.FixedWidth = true
+	return kb
+}
+
+// openCardPosition shows the positioning menu, the list order is refreshed to show the actual rank
+func openCardPosition(c *integram.Context, api *t.Client, card *t.Card) error {
+	_, err := listCards(c, api, card.List.Id)
+	if err != nil {
+		return err
+	}
+
+	c.AnswerCallbackQuery("", false)
+	return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), cardPositionKeyboard(backButtonData(c)))
+}
+
+// cardPositionButtonPressed handles the buttons of the positioning menu and the card selection for "after card"
+func cardPositionButtonPressed(c *integram.Context, api *t.Client, card *t.Card) error {
+	state := c.Callback.Message.InlineKeyboardMarkup.State
+	data := c.Callback.Data
+
+	if data == "position" {
+		return openCardPosition(c, api, card)
+	}
+
+	siblings, err := listCards(c, api, card.List.Id)
+	if err != nil {
+		return err
+	}
+
+	if data == "after" || state == "pos_after" && data == "page" {
+		page := 0
+		if data == "page" {
+			page = c.Callback.State
+		}
+		c.AnswerCallbackQuery("", false)
+		return c.EditPressedInlineKeyboard(cardAfterKeyboard(card, siblings, page))
+	}
+
+	current := -1
+	var others []*t.Card
+	for i, sibling := range siblings {
+		if sibling.Id == card.Id {
+			current = i
+		} else {
+			others = append(others, sibling)
+		}
+	}
+	if current == -1 {
+		return c.AnswerCallbackQuery("The card is not in the list anymore", false)
+	}
+
+	// k is the index in others to insert the card before
+	k := -1
+	if state == "pos_after" {
+		for i, other := range others {
+			if other.Id == data {
+				k = i + 1
+			}
+		}
+		if k == -1 {
+			return c.AnswerCallbackQuery("The card is not in the list anymore", false)
+		}
+	} else {
+		switch data {
+		case "top":
+			k = 0
+		case "up":
+			k = current - 1
+		case "down":
+			k = current + 1
+		case "bottom":
+			k = len(others)
+		}
+		if k < 0 || k > len(others) {
+			return c.AnswerCallbackQuery("The card is already there", false)
+		}
+	}
+	if k == current {
+		return c.AnswerCallbackQuery("The card is already there", false)
+	}
+
+	card.SetClient(api)
+	err = card.SetPosition(cardInsertPos(others, k))
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+	err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.pos": card.Pos}}, card)
+	if err != nil {
+		return err
+	}
+
+	order := append(append(append([]*t.Card{}, others[:k]...), card), others[k:]...)
+	setListOrder(c, card.List.Id, order)
+
+	var prev []string
+	for _, sibling := range siblings {
+		prev = append(prev, sibling.Id)
+	}
+	refreshCardRanks(c, prev, order, card.Id)
+
+	c.AnswerCallbackQuery(fmt.Sprintf("You moved card \"%s\" to #%d of %d", card.Name, k+1, len(order)), false)
+
+	text := cardText(c, card)
+	c.EditMessagesWithEventID("card_"+card.Id, "actions", text, cardInlineKeyboard(card, false))
+	c.EditMessagesWithEventID("card_"+card.Id, "created", text, cardCreatedKeyboard())
+	return c.EditPressedMessageTextAndInlineKeyboard(text, cardPositionKeyboard(backButtonData(c)))
+}
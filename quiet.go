@@ -0,0 +1,165 @@
+package trello
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/requilence/integram"
+)
+
+const quietCommandHelp = `During the quiet hours notifications are sent silently or queued and sent as one summary when the quiet hours end
+/quiet 22:00-08:00 – set the daily quiet hours
+/quiet 22:00-08:00 queue – queue created, moved, completed cards and comments instead of silent delivery, the rest are sent silently
+/quiet weekends on – make the whole weekend quiet
+/quiet off – turn the quiet hours off`
+
+// ChatQuietSettings defines when notifications are muted in the chat
+type ChatQuietSettings struct {
+	From     int    // start of the daily window, minutes since midnight
+	To       int    // end of the daily window, minutes since midnight. Can be less than From when window includes midnight
+	Weekends bool   // Saturday and Sunday are quiet for the whole day
+	Queue    bool   // queue the notifications the digest can summarize and send them when quiet hours end, the rest are sent silently
+	Timezone string // timezone of the user who set the quiet hours
+}
+
+// Enabled reports whether any quiet time is set
+func (qs ChatQuietSettings) Enabled() bool {
+	return qs.From != qs.To || qs.Weekends
+}
+
+// Location returns the timezone of the quiet hours
+func (qs ChatQuietSettings) Location() *time.Location {
+	if qs.Timezone != "" {
+		if loc, err := time.LoadLocation(qs.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+func (qs ChatQuietSettings) inWeekend(local time.Time) bool {
+	return qs.Weekends && (local.Weekday() == time.Saturday || local.Weekday() == time.Sunday)
+}
+
+func (qs ChatQuietSettings) inWindow(local time.Time) bool {
+	if qs.From == qs.To {
+		return false
+	}
+	min := local.Hour()*60 + local.Minute()
+	if qs.From < qs.To {
+		return min >= qs.From && min < qs.To
+	}
+	return min >= qs.From || min < qs.To
+}
+
+// Active reports whether tm is inside the quiet hours
+func (qs ChatQuietSettings) Active(tm time.Time) bool {
+	local := tm.In(qs.Location())
+	return qs.inWeekend(local) || qs.inWindow(local)
+}
+
+// End returns the time when the quiet hours that include tm end
+func (qs ChatQuietSettings) End(tm time.Time) time.Time {
+	local := tm.In(qs.Location())
+	// weekend and the daily window can follow each other, so jump until both are over
+	for i := 0; i < 8 && qs.Active(local); i++ {
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+		if qs.inWeekend(local) {
+			local = midnight.AddDate(0, 0, 1)
+			continue
+		}
+
+		end := midnight.Add(time.Duration(qs.To) * time.Minute)
+		if !end.After(local) {
+			end = end.AddDate(0, 0, 1)
+		}
+		local = end
+	}
+	return local
+}
+
+func (qs ChatQuietSettings) String() string {
+	if !qs.Enabled() {
+		return "no quiet hours"
+	}
+
+	var s []string
+	if qs.From != qs.To {
+		s = append(s, fmt.Sprintf("%02d:%02d-%02d:%02d", qs.From/60, qs.From%60, qs.To/60, qs.To%60))
+	}
+	if qs.Weekends {
+		s = append(s, "weekends")
+	}
+
+	mode := "silent"
+	if qs.Queue {
+		mode = "queued"
+	}
+	return fmt.Sprintf("quiet %s (%s), notifications are %s", strings.Join(s, " and "), qs.Location().String(), mode)
+}
+
+// parseQuietWindow parses the window like 22:00-08:00 into minutes since midnight
+func parseQuietWindow(s string) (from int, to int, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("wrong quiet hours %q", s)
+	}
+
+	var tm [2]time.Time
+	for i, part := range parts {
+		tm[i], err = time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return tm[0].Hour()*60 + tm[0].Minute(), tm[1].Hour()*60 + tm[1].Minute(), nil
+}
+
+func quietCommand(c *integram.Context, param string) error {
+	qs := chatSettings(c).Quiet
+	args := strings.Fields(param)
+
+	reply := func(text string) error {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(text).
+			EnableHTML().
+			Send()
+	}
+
+	if len(args) == 0 {
+		return reply("This chat has " + m.Bold(qs.String()) + "\n\n" + quietCommandHelp)
+	}
+
+	switch args[0] {
+	case "off":
+		qs = ChatQuietSettings{}
+	case "weekends":
+		qs.Weekends = len(args) < 2 || args[1] != "off"
+	default:
+		from, to, err := parseQuietWindow(args[0])
+		if err != nil {
+			return reply("Wrong quiet hours, use the 24-hour format like 22:00-08:00\n\n" + quietCommandHelp)
+		}
+		qs.From, qs.To = from, to
+		qs.Queue = len(args) > 1 && args[1] == "queue"
+	}
+	qs.Timezone = c.User.TzLocation().String()
+
+	err := c.Chat.SaveSetting("Quiet", qs)
+	if err != nil {
+		return err
+	}
+
+	// send the queued notifications in case quiet hours were changed
+	var events []digestEvent
+	if c.Chat.Cache("digest", &events) && len(events) > 0 {
+		_, err = c.Service().SheduleJob(sendDigest, 0, nextDigestTime(chatSettings(c), time.Now()), c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return reply("Done! This chat has " + m.Bold(qs.String()))
+}
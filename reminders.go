@@ -0,0 +1,206 @@
+package trello
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+// default offsets of the due date reminders in minutes
+var defaultDueReminders = []int{24 * 60, 60}
+
+const remindersCommandHelp = `Reminders are sent before the due date of the cards on the boards integrated into this chat. When the card is overdue assignees are mentioned
+/reminders 24h 1h – remind 24 hours and 1 hour before the due date
+/reminders 2d 30m – remind 2 days and 30 minutes before
+/reminders off – turn off the reminders`
+
+// dueReminders returns the chat's reminder offsets in minutes
+func (cs ChatSettings) dueReminders() []int {
+	if cs.DueReminders == nil {
+		return defaultDueReminders
+	}
+	return cs.DueReminders
+}
+
+// scheduleDueReminders schedules the reminders and the overdue alert for the card's due date into the current chat.
+// Jobs are never canceled: stale reminders are skipped when the card's due date changes or the card is archived or completed
+func scheduleDueReminders(c *integram.Context, card *t.Card) {
+	if card.Due == nil || card.Due.IsZero() || card.Closed || card.DueComplete || c.Chat.ID == 0 {
+		return
+	}
+
+	cs := chatSettings(c)
+	if cs.DueRemindersOff {
+		return
+	}
+
+	boardID := card.IdBoard
+	if card.Board != nil && card.Board.Id != "" {
+		boardID = card.Board.Id
+	}
+	if bs, ok := cs.Boards[boardID]; !ok || !bs.Enabled {
+		return
+	}
+
+	now := time.Now()
+	// zero offset is the overdue alert
+	for _, offset := range append(cs.dueReminders(), 0) {
+		at := card.Due.Add(-time.Duration(offset) * time.Minute)
+		if at.Before(now) {
+			continue
+		}
+
+		_, err := c.Service().SheduleJob(sendDueReminder, 0, at, c, card.Id, *card.Due, offset)
+		if err != nil {
+			c.Log().WithError(err).Error("Can't schedule the due reminder")
+		}
+	}
+}
+
+func sendDueReminder(c *integram.Context, cardID string, due time.Time, offset int) error {
+	card := t.Card{}
+	if !c.ServiceCache("card_"+cardID, &card) {
+		return nil
+	}
+
+	if card.Closed || card.DueComplete || card.Due == nil || !card.Due.Equal(due) {
+		return nil
+	}
+
+	cs := chatSettings(c)
+	if cs.DueRemindersOff || offset > 0 && !containsInt(cs.dueReminders(), offset) {
+		return nil
+	}
+
+	// the same reminder can be scheduled several times, f.e. when the card was stored and then updated
+	key := fmt.Sprintf("reminded_%s_%d_%d", cardID, due.Unix(), offset)
+	if sent := false; c.Chat.Cache(key, &sent) && sent {
+		return nil
+	}
+	c.Chat.SetCache(key, true, time.Hour*24*7)
+
+	var assignees []string
+	for _, member := range card.Members {
+		assignees = append(assignees, mention(c, member))
+	}
+
+	var text string
+	if offset == 0 {
+		text = fmt.Sprintf("🔥 The card %s is overdue", m.Bold(card.Name))
+	} else {
+		text = fmt.Sprintf("⏰ The card %s is due in %s", m.Bold(card.Name), dueReminderOffsetText(offset))
+	}
+	if len(assignees) > 0 {
+		text += "\n" + strings.Join(assignees, ", ")
+	}
+
+	msg := c.NewMessage().
+		EnableHTML().
+		SetSilent(cs.Quiet.Active(time.Now())).
+		SetReplyAction(cardReplied, card.Id)
+
+	if cardMsg, _ := cardMessage(c, card.Id); cardMsg != nil {
+		msg.SetReplyToMsgID(cardMsg.MsgID)
+	} else {
+		text += " " + m.URL("↗️", card.URL())
+	}
+
+	return msg.SetText(text).Send()
+}
+
+func containsInt(s []int, e int) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+func dueReminderOffsetText(minutes int) string {
+	switch {
+	case minutes%(24*60) == 0:
+		return pluralize(minutes/(24*60), "day")
+	case minutes%60 == 0:
+		return pluralize(minutes/60, "hour")
+	default:
+		return pluralize(minutes, "minute")
+	}
+}
+
+// parseReminderOffset parses the offsets like 2d, 24h or 30m into minutes
+func parseReminderOffset(s string) (int, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("wrong offset %q", s)
+		}
+		return days * 24 * 60, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("wrong offset %q", s)
+	}
+	return int(d / time.Minute), nil
+}
+
+func remindersCommand(c *integram.Context, param string) error {
+	cs := chatSettings(c)
+	args := strings.Fields(param)
+
+	reply := func(text string) error {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(text).
+			EnableHTML().
+			Send()
+	}
+
+	if len(args) == 0 {
+		status := "turned off"
+		if !cs.DueRemindersOff {
+			var offsets []string
+			for _, offset := range cs.dueReminders() {
+				offsets = append(offsets, dueReminderOffsetText(offset))
+			}
+			status = "sent " + strings.Join(offsets, ", ") + " before the due date"
+		}
+		return reply("Reminders are " + m.Bold(status) + "\n\n" + remindersCommandHelp)
+	}
+
+	if args[0] == "off" {
+		err := c.Chat.SaveSetting("DueRemindersOff", true)
+		if err != nil {
+			return err
+		}
+		return reply("Done! Reminders are turned off")
+	}
+
+	var offsets []int
+	for _, arg := range args {
+		offset, err := parseReminderOffset(arg)
+		if err != nil {
+			return reply(m.EncodeEntities(err.Error()) + "\n\n" + remindersCommandHelp)
+		}
+		if !containsInt(offsets, offset) {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+
+	err := c.Chat.SaveSetting("DueReminders", offsets)
+	if err == nil {
+		err = c.Chat.SaveSetting("DueRemindersOff", false)
+	}
+	if err != nil {
+		return err
+	}
+
+	return reply("Done! Reminders will be sent before the due date of the cards. New offsets are applied to the due dates set from now on")
+}
@@ -0,0 +1,327 @@
+package trello
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const cardReplyCommandsHelp = `Reply to the card with the command:
+/move list – move to the list
+/assign @member, /unassign @member – assign or unassign the members, separate several ones with commas
+/label name, /unlabel name – attach or remove the labels
+/due tomorrow 9am, /due off – set or clear the due date
+/archive, /unarchive – archive or restore the card
+/top, /bottom – move to the top or the bottom of the list`
+
+// fuzzyMatch returns the indexes of names that match s best: the exact match, otherwise the names starting with s,
+// otherwise the names containing s, otherwise the names that differ from s by a typo. Case-insensitive
+func fuzzyMatch(names []string, s string) []int {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil
+	}
+
+	var exact, prefix, contains, typo []int
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == s:
+			exact = append(exact, i)
+		case strings.HasPrefix(name, s):
+			prefix = append(prefix, i)
+		case strings.Contains(name, s):
+			contains = append(contains, i)
+		case len(s) > 3 && levenshtein(name, s) <= 2:
+			typo = append(typo, i)
+		}
+	}
+
+	for _, matches := range [][]int{exact, prefix, contains, typo} {
+		if len(matches) > 0 {
+			return matches
+		}
+	}
+	return nil
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// matchOne fuzzy-matches s against names and explains the failure if there is no single match
+func matchOne(kind string, names []string, s string) (int, error) {
+	matches := fuzzyMatch(names, s)
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return -1, fmt.Errorf("Can't find the %s %s. Available: %s", kind, m.Bold(s), m.EncodeEntities(strings.Join(names, ", ")))
+	}
+
+	var found []string
+	for _, i := range matches {
+		found = append(found, names[i])
+	}
+	return -1, fmt.Errorf("🤔 %s matches several %ss: %s. Please be more specific", m.Bold(s), kind, m.EncodeEntities(strings.Join(found, ", ")))
+}
+
+// cardReplyCommand performs the command sent in reply to the card message. ok is false for the unknown commands
+func cardReplyCommand(c *integram.Context, cardID string, command string, param string) (ok bool, err error) {
+	switch command {
+	case "move", "assign", "unassign", "label", "unlabel", "due", "archive", "unarchive", "top", "bottom":
+	case "help":
+		return true, c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText(cardReplyCommandsHelp).Send()
+	default:
+		return false, nil
+	}
+
+	api := api(c)
+	card, err := getCard(c, api, cardID)
+	if err != nil {
+		return true, err
+	}
+	card.SetClient(api)
+	param = strings.TrimSpace(param)
+
+	var text string
+	switch command {
+	case "move":
+		text, err = cardReplyMove(c, api, card, param)
+	case "assign", "unassign":
+		text, err = cardReplyAssign(c, api, card, param, command == "unassign")
+	case "label", "unlabel":
+		text, err = cardReplyLabel(c, api, card, param, command == "unlabel")
+	case "due":
+		text, err = cardReplyDue(c, card, param)
+	case "archive", "unarchive":
+		closed := command == "archive"
+		_, err = api.Request("PUT", "cards/"+card.Id+"/closed", nil, url.Values{"value": {fmt.Sprintf("%v", closed)}})
+		if t.IsBadToken(err) {
+			c.User.ResetOAuthToken()
+		}
+		if err == nil {
+			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.closed": closed}}, card)
+			text = "📦 Card archived"
+			if !closed {
+				text = "📤 Card restored"
+			}
+		}
+	case "top", "bottom":
+		err = card.SetPosition(command)
+		if err == nil {
+			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.pos": card.Pos}}, card)
+			text = "Card moved to the " + command + " of the list"
+		}
+	}
+
+	if err != nil {
+		if ue, isUserError := err.(cardReplyError); isUserError {
+			return true, c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText(ue.Error()).EnableHTML().Send()
+		}
+		return true, err
+	}
+
+	return true, c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText(text).EnableHTML().Send()
+}
+
+// cardReplyError is the error caused by the command's argument, it is reported to the user
+type cardReplyError struct {
+	error
+}
+
+func cardReplyMove(c *integram.Context, api *t.Client, card *t.Card, param string) (string, error) {
+	if param == "" {
+		return "", cardReplyError{fmt.Errorf("Write the list name, e.g. /move Done")}
+	}
+
+	lists, err := listsByBoardID(c, api, card.Board.Id)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, list := range lists {
+		names = append(names, list.Name)
+	}
+	i, err := matchOne("list", names, param)
+	if err != nil {
+		return "", cardReplyError{err}
+	}
+
+	err = moveCard(c, api, lists[i].Id, card)
+	if err != nil {
+		return "", err
+	}
+	return "📁 Card moved to " + m.Bold(lists[i].Name), nil
+}
+
+func cardReplyAssign(c *integram.Context, api *t.Client, card *t.Card, param string, unassign bool) (string, error) {
+	if param == "" {
+		return "", cardReplyError{fmt.Errorf("Write the member, e.g. /assign @bob")}
+	}
+
+	members, err := membersByBoardID(c, api, card.Board.Id)
+	if err != nil {
+		return "", err
+	}
+	var usernames, fullNames []string
+	for _, member := range members {
+		usernames = append(usernames, member.Username)
+		fullNames = append(fullNames, member.FullName)
+	}
+
+	var done []string
+	// full names have spaces, so only commas separate the members
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+		if name == "" {
+			continue
+		}
+
+		var member *t.Member
+		if strings.EqualFold(name, "me") {
+			member, err = me(c, api)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			i, err := matchOne("member", usernames, name)
+			// full names are tried only if no username matches, the ambiguous username is reported as is
+			if err != nil && len(fuzzyMatch(usernames, name)) == 0 {
+				i, err = matchOne("member", fullNames, name)
+			}
+			if err != nil {
+				return "", cardReplyError{err}
+			}
+			member = members[i]
+		}
+
+		assigned := integram.SliceContainsString(card.IdMembers, member.Id)
+		for _, cardMember := range card.Members {
+			assigned = assigned || cardMember.Id == member.Id
+		}
+		if assigned && !unassign {
+			return "", cardReplyError{fmt.Errorf("%s is already assigned", mention(c, member))}
+		} else if !assigned && unassign {
+			return "", cardReplyError{fmt.Errorf("%s is not assigned", mention(c, member))}
+		}
+
+		_, _, err = assignMemberID(c, api, member.Id, unassign, card)
+		if err != nil {
+			return "", err
+		}
+		done = append(done, mention(c, member))
+	}
+	if len(done) == 0 {
+		return "", cardReplyError{fmt.Errorf("Write the member, e.g. /assign @bob")}
+	}
+
+	if unassign {
+		return "👤 Unassigned " + strings.Join(done, ", "), nil
+	}
+	return "👤 Assigned " + strings.Join(done, ", "), nil
+}
+
+func cardReplyLabel(c *integram.Context, api *t.Client, card *t.Card, param string, unlabel bool) (string, error) {
+	if param == "" {
+		return "", cardReplyError{fmt.Errorf("Write the label, e.g. /label bug")}
+	}
+
+	labels, err := labelsByBoardID(c, api, card.Board.Id)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, label := range labels {
+		name := label.Name
+		if name == "" {
+			name = label.Color
+		}
+		names = append(names, name)
+	}
+
+	var done []string
+	for _, name := range strings.Split(param, ",") {
+		i, err := matchOne("label", names, strings.TrimPrefix(strings.TrimSpace(name), "#"))
+		if err != nil {
+			return "", cardReplyError{err}
+		}
+
+		attached := false
+		for _, label := range card.Labels {
+			attached = attached || label.Id == labels[i].Id
+		}
+		if attached && !unlabel {
+			return "", cardReplyError{fmt.Errorf("The label %s is already attached", m.Bold(names[i]))}
+		} else if !attached && unlabel {
+			return "", cardReplyError{fmt.Errorf("The label %s is not attached", m.Bold(names[i]))}
+		}
+
+		_, _, err = attachLabelID(c, api, labels[i].Id, unlabel, card)
+		if err != nil {
+			return "", err
+		}
+		done = append(done, colorEmoji(labels[i].Color)+" "+m.Bold(names[i]))
+	}
+
+	if unlabel {
+		return "🏷 Removed " + strings.Join(done, ", "), nil
+	}
+	return "🏷 Attached " + strings.Join(done, ", "), nil
+}
+
+func cardReplyDue(c *integram.Context, card *t.Card, param string) (string, error) {
+	switch strings.ToLower(param) {
+	case "":
+		return "", cardReplyError{fmt.Errorf("Write the due date, %s", dueDateHelp)}
+	case "off", "clear", "none", "remove":
+		_, err := cardSetDue(c, card, time.Time{})
+		if err != nil {
+			return "", err
+		}
+		return "📅 Due date cleared", nil
+	}
+
+	dt, err := parseUserDueDate(c, param)
+	if err != nil {
+		return "", cardReplyError{fmt.Errorf("%s\nWrite the due date, %s", m.EncodeEntities(err.Error()), dueDateHelp)}
+	}
+
+	_, err = cardSetDue(c, card, dt)
+	if err != nil {
+		return "", err
+	}
+	return "📅 Due date set to " + m.Bold(dueDatePreview(c, dt)), nil
+}
@@ -0,0 +1,343 @@
+package trello
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+// fields of the webhook that can be matched by the filter rules
+var ruleFields = []string{"name", "desc", "comment", "list", "label", "member", "type"}
+
+const filterCommandHelp = `Filter rules have the form <b>only|drop field pattern</b>
+Fields: <i>name, desc, comment, list, label, member, type</i>
+Pattern is a case-insensitive keyword or a regular expression inside slashes
+
+Examples:
+/filter only name /^\[P0\]/
+/filter drop comment /^\/bot/
+/filter drop type addMemberToCard
+/filter remove 1 – remove the first rule
+/filter clear – remove all rules`
+
+// ruleRegexps holds the compiled patterns of the regexp rules, rules are stored in the chat settings without them
+var ruleRegexps sync.Map
+
+// ruleRegexp returns the compiled pattern, it is compiled once per process
+func ruleRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := ruleRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ruleRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// ChatBoardRule drops or passes the notification when the webhook's field matches the pattern
+type ChatBoardRule struct {
+	Drop    bool   // drop the matched notifications. Otherwise notify only about the matched ones
+	Field   string // one of ruleFields
+	Pattern string
+	Regexp  bool // Pattern is regular expression, otherwise keyword
+}
+
+// String returns the rule in the same form it can be set with /filter
+func (r ChatBoardRule) String() string {
+	s := "only "
+	if r.Drop {
+		s = "drop "
+	}
+	s += r.Field + " "
+	if r.Regexp {
+		return s + "/" + r.Pattern + "/"
+	}
+	return s + r.Pattern
+}
+
+func parseFilterRule(expr string) (ChatBoardRule, error) {
+	r := ChatBoardRule{}
+	parts := strings.Fields(expr)
+	if len(parts) < 3 {
+		return r, errors.New("rule must have the form: only|drop field pattern")
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "only":
+	case "drop":
+		r.Drop = true
+	default:
+		return r, fmt.Errorf("unknown rule action %q, use only or drop", parts[0])
+	}
+
+	r.Field = strings.ToLower(parts[1])
+	if !integram.SliceContainsString(ruleFields, r.Field) {
+		return r, fmt.Errorf("unknown field %q, use one of: %s", parts[1], strings.Join(ruleFields, ", "))
+	}
+
+	// pattern is the rest of expression and may contain spaces
+	pattern := strings.TrimSpace(expr)
+	for i := 0; i < 2; i++ {
+		pattern = strings.TrimSpace(pattern[len(strings.Fields(pattern)[0]):])
+	}
+
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		r.Regexp = true
+		pattern = pattern[1 : len(pattern)-1]
+		if _, err := ruleRegexp(pattern); err != nil {
+			return r, fmt.Errorf("bad regular expression: %v", err)
+		}
+	}
+	r.Pattern = pattern
+
+	return r, nil
+}
+
+// values returns the values of the rule's field for the webhook action.
+// card is the action's card, webhookHandler fills it from the cached card except for createCard, that has only the action's data
+func (r ChatBoardRule) values(wh *webhook, card *t.Card) []string {
+	var values []string
+	switch r.Field {
+	case "name":
+		if card != nil {
+			values = append(values, card.Name)
+		}
+	case "desc":
+		if card != nil {
+			values = append(values, card.Desc)
+		}
+	case "comment":
+		values = append(values, wh.Action.Data.Text)
+	case "list":
+		if card != nil && card.List != nil {
+			values = append(values, card.List.Name)
+		}
+		values = append(values, wh.Action.Data.List.Name)
+		if wh.Action.Data.ListBefore != nil {
+			values = append(values, wh.Action.Data.ListBefore.Name)
+		}
+		if wh.Action.Data.ListAfter != nil {
+			values = append(values, wh.Action.Data.ListAfter.Name)
+		}
+	case "label":
+		if card != nil {
+			for _, label := range card.Labels {
+				values = append(values, label.Name)
+			}
+		}
+		if wh.Action.Data.Label != nil {
+			values = append(values, wh.Action.Data.Label.Name)
+		}
+	case "member":
+		var members []*t.Member
+		if card != nil {
+			members = append(members, card.Members...)
+		}
+		if wh.Action.Member != nil {
+			members = append(members, wh.Action.Member)
+		}
+		for _, member := range members {
+			values = append(values, member.Username, member.FullName)
+		}
+	case "type":
+		values = append(values, wh.Action.Type)
+	}
+	return values
+}
+
+func (r ChatBoardRule) match(wh *webhook, card *t.Card) bool {
+	var re *regexp.Regexp
+	if r.Regexp {
+		var err error
+		re, err = ruleRegexp(r.Pattern)
+		if err != nil {
+			return false
+		}
+	}
+
+	for _, value := range r.values(wh, card) {
+		if value == "" {
+			continue
+		}
+		if re != nil {
+			if re.MatchString(value) {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(value), strings.ToLower(r.Pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAction evaluates the board's rules. Notification passes when it matches all the "only" rules and none of the "drop" ones
+func (bs ChatBoardSetting) allowsAction(wh *webhook, card *t.Card) bool {
+	for _, rule := range bs.Rules {
+		if rule.match(wh, card) == rule.Drop {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedBoardIDs returns IDs of the chat's enabled boards sorted by name
+func sortedBoardIDs(cs ChatSettings) []string {
+	var ids []string
+	for id, bs := range cs.Boards {
+		if bs.Enabled {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return cs.Boards[ids[i]].Name < cs.Boards[ids[j]].Name
+	})
+	return ids
+}
+
+func filterCommand(c *integram.Context, param string) error {
+	cs := chatSettings(c)
+	boardIDs := sortedBoardIDs(cs)
+
+	if len(boardIDs) == 0 {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("There are no boards integrated into this chat. Use /connect first").
+			Send()
+	}
+
+	param = strings.TrimSpace(param)
+	args := strings.Fields(param)
+
+	switch {
+	case param == "":
+		return sendFilterRules(c, cs, boardIDs)
+	case args[0] == "clear":
+		for _, id := range boardIDs {
+			bs := cs.Boards[id]
+			bs.Rules = nil
+			cs.Boards[id] = bs
+		}
+		err := c.Chat.SaveSettings(cs)
+		if err != nil {
+			return err
+		}
+		return sendFilterRules(c, cs, boardIDs)
+	case args[0] == "remove":
+		n := 0
+		if len(args) == 2 {
+			n, _ = strconv.Atoi(args[1])
+		}
+		// rules are numbered across all boards in the same order as in sendFilterRules
+		for _, id := range boardIDs {
+			bs := cs.Boards[id]
+			if n > 0 && n <= len(bs.Rules) {
+				bs.Rules = append(bs.Rules[:n-1], bs.Rules[n:]...)
+				cs.Boards[id] = bs
+				err := c.Chat.SaveSettings(cs)
+				if err != nil {
+					return err
+				}
+				return sendFilterRules(c, cs, boardIDs)
+			}
+			n -= len(bs.Rules)
+		}
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText("Can't find the rule with this number. Send /filter to see the list of rules").
+			Send()
+	}
+
+	rule, err := parseFilterRule(param)
+	if err != nil {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(m.EncodeEntities(err.Error()) + "\n\n" + filterCommandHelp).
+			EnableHTML().
+			Send()
+	}
+
+	if len(boardIDs) == 1 {
+		return addFilterRule(c, boardIDs[0], rule)
+	}
+
+	buttons := integram.Buttons{}
+	for _, id := range boardIDs {
+		buttons.Append(id, cs.Boards[id].Name)
+	}
+
+	return c.NewMessage().
+		SetText(fmt.Sprintf("%v select the board to add the rule", c.User.Mention())).
+		SetKeyboard(buttons.Markup(1), true).
+		SetSilent(true).
+		SetReplyToMsgID(c.Message.MsgID).
+		SetReplyAction(filterRuleBoardSelected, rule).
+		Send()
+}
+
+func filterRuleBoardSelected(c *integram.Context, rule ChatBoardRule) error {
+	boardID, _ := c.KeyboardAnswer()
+	if boardID == "" {
+		return nil
+	}
+
+	return addFilterRule(c, boardID, rule)
+}
+
+func addFilterRule(c *integram.Context, boardID string, rule ChatBoardRule) error {
+	cs := chatSettings(c)
+	bs, ok := cs.Boards[boardID]
+	if !ok {
+		return errors.New("Can't find board settings on user")
+	}
+
+	bs.Rules = append(bs.Rules, rule)
+	cs.Boards[boardID] = bs
+	err := c.Chat.SaveSettings(cs)
+	if err != nil {
+		return err
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(fmt.Sprintf("Rule %s added to the %s board", m.Fixed(rule.String()), m.Bold(bs.Name))).
+		EnableHTML().
+		HideKeyboard().
+		Send()
+}
+
+func sendFilterRules(c *integram.Context, cs ChatSettings, boardIDs []string) error {
+	text := ""
+	n := 1
+	for _, id := range boardIDs {
+		bs := cs.Boards[id]
+		if len(bs.Rules) == 0 {
+			continue
+		}
+		text += m.Bold(bs.Name) + "\n"
+		for _, rule := range bs.Rules {
+			text += fmt.Sprintf("%d. %s\n", n, m.Fixed(rule.String()))
+			n++
+		}
+		text += "\n"
+	}
+
+	if text == "" {
+		text = "There are no filter rules yet\n\n"
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(text + filterCommandHelp).
+		EnableHTML().
+		HideKeyboard().
+		Send()
+}
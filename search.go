@@ -0,0 +1,326 @@
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+const searchFields = "name,idBoard,idList,idMembers,labels,due,dueComplete,closed,pos,shortUrl,dateLastActivity"
+
+// how long the results are kept to turn the pages
+const searchResultsTTL = time.Minute * 5
+
+const searchCommandHelp = `Use /search with the text and operators:
+<b>board:</b>name, <b>list:</b>name, <b>label:</b>name – cards on the board, in the list or with the label
+<b>@username</b> – cards assigned to the member
+<b>due:overdue</b> – overdue cards
+<b>is:archived</b> – archived cards
+Values with spaces can be quoted: list:"In progress"`
+
+// cardSearchQuery is the parsed /search query. String fields are matched case-insensitively by substring
+type cardSearchQuery struct {
+	Text     string
+	Board    string
+	List     string
+	Label    string
+	Member   string
+	Overdue  bool
+	Archived bool
+}
+
+// splitQuery splits the query by spaces except the quoted parts, quotes are removed
+func splitQuery(q string) []string {
+	var tokens []string
+	var token []rune
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if len(token) > 0 {
+				tokens = append(tokens, string(token))
+				token = nil
+			}
+		default:
+			token = append(token, r)
+		}
+	}
+	if len(token) > 0 {
+		tokens = append(tokens, string(token))
+	}
+	return tokens
+}
+
+func parseSearchQuery(q string) cardSearchQuery {
+	var sq cardSearchQuery
+	var text []string
+
+	for _, token := range splitQuery(q) {
+		lower := strings.ToLower(token)
+		switch {
+		case strings.HasPrefix(lower, "board:"):
+			sq.Board = lower[len("board:"):]
+		case strings.HasPrefix(lower, "list:"):
+			sq.List = lower[len("list:"):]
+		case strings.HasPrefix(lower, "label:"):
+			sq.Label = lower[len("label:"):]
+		case strings.HasPrefix(lower, "@") && len(lower) > 1:
+			sq.Member = lower[1:]
+		case lower == "due:overdue":
+			sq.Overdue = true
+		case lower == "is:archived":
+			sq.Archived = true
+		default:
+			text = append(text, token)
+		}
+	}
+	sq.Text = strings.Join(text, " ")
+	return sq
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), substr)
+}
+
+// match checks the card against the query operators. Text is matched by Trello search
+func (sq cardSearchQuery) match(c *integram.Context, api *t.Client, card *t.Card) bool {
+	if card.Closed != sq.Archived {
+		return false
+	}
+
+	if sq.Overdue && !isOverdue(card) {
+		return false
+	}
+
+	if sq.List != "" {
+		lists, err := listsByBoardID(c, api, card.IdBoard)
+		if err != nil {
+			return false
+		}
+		list := listsFilterByID(lists, card.IdList)
+		if list == nil || !containsFold(list.Name, sq.List) {
+			return false
+		}
+	}
+
+	if sq.Label != "" {
+		found := false
+		for _, label := range card.Labels {
+			if containsFold(label.Name, sq.Label) || strings.ToLower(label.Color) == sq.Label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if sq.Member != "" {
+		members, err := membersByBoardID(c, api, card.IdBoard)
+		if err != nil {
+			return false
+		}
+		found := false
+		for _, member := range members {
+			if integram.SliceContainsString(card.IdMembers, member.Id) && (containsFold(member.Username, sq.Member) || containsFold(member.FullName, sq.Member)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// searchCards returns the cards from the user's boards that match the query
+func searchCards(c *integram.Context, q string) ([]*t.Card, error) {
+	api := api(c)
+	sq := parseSearchQuery(q)
+
+	boards, err := boards(c, api)
+	if err != nil {
+		return nil, err
+	}
+
+	var boardIDs []string
+	for _, board := range boards {
+		if sq.Board == "" || containsFold(board.Name, sq.Board) {
+			boardIDs = append(boardIDs, board.Id)
+		}
+	}
+	if len(boardIDs) == 0 {
+		return nil, nil
+	}
+
+	var cards []*t.Card
+	if sq.Text != "" {
+		query := sq.Text
+		if sq.Archived {
+			query += " is:archived"
+		}
+		var res struct {
+			Cards []*t.Card
+		}
+		b, err := api.Request("GET", "search", nil, url.Values{"query": {query}, "modelTypes": {"cards"}, "idBoards": {strings.Join(boardIDs, ",")}, "partial": {"true"}, "cards_limit": {"100"}, "card_fields": {searchFields}})
+		if t.IsBadToken(err) {
+			c.User.ResetOAuthToken()
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, &res)
+		if err != nil {
+			return nil, err
+		}
+		cards = res.Cards
+	} else {
+		filter := "open"
+		if sq.Archived {
+			filter = "closed"
+		}
+		for _, boardID := range boardIDs {
+			var bcards []*t.Card
+			b, err := api.Request("GET", "boards/"+boardID+"/cards", nil, url.Values{"filter": {filter}, "fields": {searchFields}})
+			if err != nil {
+				return nil, err
+			}
+			err = json.Unmarshal(b, &bcards)
+			if err != nil {
+				return nil, err
+			}
+			cards = append(cards, bcards...)
+		}
+	}
+
+	var res []*t.Card
+	for _, card := range cards {
+		if sq.match(c, api, card) {
+			res = append(res, card)
+		}
+	}
+	return res, nil
+}
+
+// searchResult is the found card. Results are cached in the chat's cache by the results message to turn its pages
+// without repeating the search, so any member turns the same results
+type searchResult struct {
+	ID      string
+	Name    string
+	Overdue bool
+}
+
+// searchResultsKey is the cache key of the results message, it replies to the /search command message
+func searchResultsKey(commandMsgID int) string {
+	return fmt.Sprintf("search_%d", commandMsgID)
+}
+
+// cachedSearch returns the results cached by key, the search is performed if they are expired or fresh is set
+func cachedSearch(c *integram.Context, q string, key string, fresh bool) ([]searchResult, error) {
+	var results []searchResult
+	if !fresh && c.Chat.Cache(key, &results) {
+		return results, nil
+	}
+
+	cards, err := searchCards(c, q)
+	if err != nil {
+		return nil, err
+	}
+	results = make([]searchResult, len(cards))
+	for i, card := range cards {
+		results[i] = searchResult{ID: card.Id, Name: card.Name, Overdue: isOverdue(card)}
+	}
+
+	err = c.Chat.SetCache(key, results, searchResultsTTL)
+	if err != nil {
+		c.Log().WithError(err).Error("can't cache the search results")
+	}
+	return results, nil
+}
+
+// searchResults renders the page of the results, fresh is set to search again instead of using the cached results
+func searchResults(c *integram.Context, q string, key string, page int, fresh bool) (string, integram.InlineKeyboard, error) {
+	cards, err := cachedSearch(c, q, key, fresh)
+	if err != nil {
+		return "", integram.InlineKeyboard{}, err
+	}
+
+	if len(cards) == 0 {
+		return fmt.Sprintf("Nothing found for %s", m.Fixed(q)), integram.InlineKeyboard{}, nil
+	}
+
+	buttons := integram.InlineButtons{}
+	for _, card := range cards {
+		text := card.Name
+		if card.Overdue {
+			text = "🔥 " + text
+		}
+		buttons.Append("c_"+card.ID, text)
+	}
+
+	text := fmt.Sprintf("🔎 %s found for %s", pluralize(len(cards), "card"), m.Fixed(q))
+	pages := (len(cards) + inlinePageSize - 1) / inlinePageSize
+	if pages > 1 {
+		text += fmt.Sprintf(", page %d of %d", page+1, pages)
+	}
+
+	return text, inlinePage(buttons, page, "page", "results"), nil
+}
+
+func searchCommand(c *integram.Context, q string) error {
+	if !c.User.OAuthValid() {
+		kb := integram.InlineKeyboard{}
+		kb.AddPMSwitchButton(c.Bot(), "👉  Tap me to auth", "auth")
+
+		return c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("You need to auth me to search the cards").SetInlineKeyboard(kb).Send()
+	}
+
+	text, kb, err := searchResults(c, q, searchResultsKey(c.Message.MsgID), 0, true)
+	if err != nil {
+		return err
+	}
+
+	msg := c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(text).
+		EnableHTML()
+
+	if len(kb.Buttons) == 0 {
+		return msg.Send()
+	}
+
+	return msg.SetInlineKeyboard(kb).
+		SetCallbackAction(searchResultPressed, q).
+		Send()
+}
+
+func searchResultPressed(c *integram.Context, q string) error {
+	if !c.User.OAuthValid() {
+		return c.AnswerCallbackQuery("You need to authorize me\nUse the /start command in the private chat with me", true)
+	}
+
+	if strings.HasPrefix(c.Callback.Data, "c_") {
+		return sendCard(c, strings.TrimPrefix(c.Callback.Data, "c_"))
+	}
+
+	// the results message without the command to reply is searched again
+	commandMsgID := c.Callback.Message.ReplyToMsgID
+	text, kb, err := searchResults(c, q, searchResultsKey(commandMsgID), c.Callback.State, commandMsgID == 0)
+	if err != nil {
+		return err
+	}
+	if len(kb.Buttons) == 0 {
+		return c.EditPressedMessageText(text)
+	}
+	return c.EditPressedMessageTextAndInlineKeyboard(text, kb)
+}
@@ -0,0 +1,343 @@
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+const standupCommandHelp = `Standup report lists the cards moved to the last list, created and overdue cards and the cards in progress of every member since the previous report
+/standup 09:30 – send the report every day at 09:30
+/standup boards – choose the boards to include
+/standup now – send the report right now
+/standup off – turn the report off`
+
+// ChatStandupSettings defines the daily standup report of the chat
+type ChatStandupSettings struct {
+	Enabled    bool
+	Hour       int
+	Minute     int
+	Timezone   string    // timezone of the user who set the report
+	Boards     []string  // IDs of the boards to include, empty means all integrated boards
+	Next       time.Time // time of the scheduled report. Jobs with another time are stale
+	LastReport time.Time
+}
+
+// Location returns the timezone of the report
+func (ss ChatStandupSettings) Location() *time.Location {
+	if ss.Timezone != "" {
+		if loc, err := time.LoadLocation(ss.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+func (ss ChatStandupSettings) nextTime(from time.Time) time.Time {
+	local := from.In(ss.Location())
+	next := time.Date(local.Year(), local.Month(), local.Day(), ss.Hour, ss.Minute, 0, 0, local.Location())
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// standupBoardIDs returns the chat's boards included into the report
+func standupBoardIDs(cs ChatSettings) []string {
+	var ids []string
+	for _, id := range sortedBoardIDs(cs) {
+		if len(cs.Standup.Boards) == 0 || integram.SliceContainsString(cs.Standup.Boards, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// boardOpenCards returns the board's open cards with the fields enough to render the lists of cards
+func boardOpenCards(c *integram.Context, api *t.Client, boardID string) ([]*t.Card, error) {
+	var cards []*t.Card
+	b, err := api.Request("GET", "boards/"+boardID+"/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,idMembers,pos,due,dueComplete,start,idBoard,idList,idLabels,shortUrl,dateLastActivity"}})
+
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &cards)
+	return cards, err
+}
+
+// boardActions returns the board's actions of the types since the time
+func boardActions(c *integram.Context, api *t.Client, boardID string, types string, since time.Time) ([]action, error) {
+	var actions []action
+	b, err := api.Request("GET", "boards/"+boardID+"/actions", nil, url.Values{"filter": {types}, "since": {since.UTC().Format(time.RFC3339)}, "limit": {"1000"}})
+
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &actions)
+	return actions, err
+}
+
+func standupText(c *integram.Context, boardID string, boardName string, since time.Time) (string, error) {
+	api := api(c)
+
+	lists, err := listsByBoardID(c, api, boardID)
+	if err != nil {
+		return "", err
+	}
+	members, err := membersByBoardID(c, api, boardID)
+	if err != nil {
+		return "", err
+	}
+	cards, err := boardOpenCards(c, api, boardID)
+	if err != nil {
+		return "", err
+	}
+	actions, err := boardActions(c, api, boardID, "createCard,updateCard:idList", since)
+	if err != nil {
+		return "", err
+	}
+
+	doneListID := ""
+	if len(lists) > 0 {
+		doneListID = lists[len(lists)-1].Id
+	}
+
+	var done, created []string
+	seen := map[string]bool{}
+	for _, a := range actions {
+		card := a.Data.Card
+		link := m.URL(card.Name, card.URL())
+
+		switch {
+		case a.Type == "createCard" && !seen["c"+card.Id]:
+			seen["c"+card.Id] = true
+			created = append(created, link)
+		case a.Type == "updateCard" && a.Data.ListAfter != nil && a.Data.ListAfter.ID == doneListID && !seen["d"+card.Id]:
+			seen["d"+card.Id] = true
+			done = append(done, link)
+		}
+	}
+
+	var overdue []string
+	inProgress := map[string][]string{}
+	now := time.Now()
+	for _, card := range cards {
+		link := m.URL(card.Name, card.URL())
+		if card.Due != nil && card.Due.Before(now) && !card.DueComplete {
+			overdue = append(overdue, link)
+		}
+
+		// cards that are not in the first (backlog) and the last (done) lists are in progress
+		if len(lists) > 2 && card.IdList != lists[0].Id && card.IdList != doneListID {
+			for _, memberID := range card.IdMembers {
+				inProgress[memberID] = append(inProgress[memberID], link)
+			}
+		}
+	}
+
+	text := fmt.Sprintf("☀️ Standup for %s\n", m.Bold(boardName))
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		text += "\n" + m.Bold(title) + "\n• " + strings.Join(items, "\n• ") + "\n"
+	}
+
+	if doneListID != "" {
+		section("✅ Moved to "+lists[len(lists)-1].Name, done)
+	}
+	section("🆕 Created", created)
+	section("🔥 Overdue", overdue)
+
+	var progress []string
+	for _, member := range members {
+		if len(inProgress[member.Id]) > 0 {
+			progress = append(progress, mention(c, member)+": "+strings.Join(inProgress[member.Id], ", "))
+		}
+	}
+	section("👤 In progress", progress)
+
+	if len(done)+len(created)+len(overdue)+len(progress) == 0 {
+		text += "\nNothing happened since the last report"
+	}
+
+	return text, nil
+}
+
+// sendStandup sends the report for the chat's boards. Scheduled reports reschedule themselves for the next day
+func sendStandup(c *integram.Context, scheduledAt time.Time) error {
+	cs := chatSettings(c)
+	ss := cs.Standup
+
+	if !scheduledAt.IsZero() {
+		if !ss.Enabled || !ss.Next.Equal(scheduledAt) {
+			// report was turned off or rescheduled
+			return nil
+		}
+
+		ss.Next = ss.nextTime(time.Now())
+		err := c.Chat.SaveSetting("Standup.Next", ss.Next)
+		if err != nil {
+			return err
+		}
+		_, err = c.Service().SheduleJob(sendStandup, 0, ss.Next, c, ss.Next)
+		if err != nil {
+			return err
+		}
+	}
+
+	since := ss.LastReport
+	if since.IsZero() || time.Since(since) > time.Hour*24*7 {
+		since = time.Now().Add(-time.Hour * 24)
+	}
+
+	for _, boardID := range standupBoardIDs(cs) {
+		text, err := standupText(c, boardID, cs.Boards[boardID].Name, since)
+		if err != nil {
+			c.Log().WithError(err).WithField("board", boardID).Error("Can't build the standup report")
+			continue
+		}
+
+		err = c.NewMessage().
+			SetText(text).
+			EnableHTML().
+			DisableWebPreview().
+			SetSilent(cs.Quiet.Active(time.Now())).
+			Send()
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Chat.SaveSetting("Standup.LastReport", time.Now())
+}
+
+func standupCommand(c *integram.Context, param string) error {
+	cs := chatSettings(c)
+	ss := cs.Standup
+	args := strings.Fields(param)
+
+	reply := func(text string) error {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(text).
+			EnableHTML().
+			HideKeyboard().
+			Send()
+	}
+
+	if len(cs.Boards) == 0 {
+		return reply("There are no boards integrated into this chat. Use /connect first")
+	}
+
+	if len(args) == 0 {
+		status := "turned off"
+		if ss.Enabled {
+			status = fmt.Sprintf("sent every day at %02d:%02d (%s)", ss.Hour, ss.Minute, ss.Location().String())
+		}
+		return reply("Standup report is " + m.Bold(status) + "\n\n" + standupCommandHelp)
+	}
+
+	switch args[0] {
+	case "off":
+		ss.Enabled = false
+	case "now":
+		_, err := c.Service().DoJob(sendStandup, c, time.Time{})
+		return err
+	case "boards":
+		return sendStandupBoardsKeyboard(c)
+	default:
+		tm, err := time.Parse("15:04", args[0])
+		if err != nil {
+			return reply("Wrong time, use the 24-hour format like 09:30\n\n" + standupCommandHelp)
+		}
+		ss.Enabled = true
+		ss.Hour, ss.Minute = tm.Hour(), tm.Minute()
+		ss.Timezone = c.User.TzLocation().String()
+		ss.Next = ss.nextTime(time.Now())
+
+		_, err = c.Service().SheduleJob(sendStandup, 0, ss.Next, c, ss.Next)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := c.Chat.SaveSetting("Standup", ss)
+	if err != nil {
+		return err
+	}
+
+	if !ss.Enabled {
+		return reply("Done! Standup report is turned off")
+	}
+	return reply(fmt.Sprintf("Done! Standup report will be sent every day at %02d:%02d (%s)", ss.Hour, ss.Minute, ss.Location().String()))
+}
+
+func sendStandupBoardsKeyboard(c *integram.Context) error {
+	cs := chatSettings(c)
+	included := standupBoardIDs(cs)
+
+	buttons := integram.Buttons{}
+	for _, id := range sortedBoardIDs(cs) {
+		text := cs.Boards[id].Name
+		if integram.SliceContainsString(included, id) {
+			text = markSign + text
+		}
+		buttons.Append(id, text)
+	}
+	buttons.Append("finish", "🏁 Finish")
+
+	return c.NewMessage().
+		SetText(fmt.Sprintf("%v select the boards to include into the standup report", c.User.Mention())).
+		SetKeyboard(buttons.Markup(1), true).
+		SetSilent(true).
+		SetReplyToMsgID(c.Message.MsgID).
+		SetReplyAction(standupBoardsButtonPressed).
+		Send()
+}
+
+func standupBoardsButtonPressed(c *integram.Context) error {
+	answer, _ := c.KeyboardAnswer()
+	if answer == "finish" {
+		return c.NewMessage().
+			SetText("Standup report boards saved").
+			HideKeyboard().
+			Send()
+	}
+
+	cs := chatSettings(c)
+	if _, ok := cs.Boards[answer]; !ok {
+		return nil
+	}
+
+	// empty list means all boards, so it is expanded before the first toggle
+	f := ChatBoardIDFilter{IDs: standupBoardIDs(cs)}
+	f.Toggle(answer)
+	if len(f.IDs) == 0 {
+		return c.NewMessage().
+			SetText("At least one board should be included").
+			Send()
+	}
+
+	err := c.Chat.SaveSetting("Standup.Boards", f.IDs)
+	if err != nil {
+		return err
+	}
+
+	return sendStandupBoardsKeyboard(c)
+}
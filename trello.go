@@ -43,9 +43,8 @@ type Config struct {
 var defaultBoardFilter = ChatBoardFilterSettings{CardCreated: true, CardCommented: true, CardMoved: true, PersonAssigned: true, Archived: true, Due: true}
 
 const (
-	markSign          = "✅ "
-	dueDateFormat     = "02.01 15:04"
-	dueDateFullFormat = "02.01.2006 15:04"
+	markSign      = "✅ "
+	dueDateFormat = "02.01 15:04"
 )
 
 const (
@@ -87,10 +86,16 @@ func (cfg Config) Service() *integram.Service {
 			{subscribeBoard, 10, integram.JobRetryFibonacci},
 			{cacheAllCards, 1, integram.JobRetryFibonacci},
 			{commentCard, 10, integram.JobRetryFibonacci},
+			{createChecklistFromText, 1, integram.JobRetryFibonacci},
 			{downloadAttachment, 10, integram.JobRetryFibonacci},
 			{removeFile, 1, integram.JobRetryFibonacci},
 			{attachFileToCard, 3, integram.JobRetryFibonacci},
 			{resubscribeAllBoards, 1, integram.JobRetryFibonacci},
+			{sendDigest, 1, integram.JobRetryFibonacci},
+			{sendDueReminder, 1, integram.JobRetryFibonacci},
+			{sendStandup, 1, integram.JobRetryFibonacci},
+			{refreshMyTasks, 1, integram.JobRetryFibonacci},
+			{pinMyTasks, 5, integram.JobRetryFibonacci},
 		},
 		Actions: []interface{}{
 			boardToIntegrateSelected,
@@ -102,13 +107,22 @@ func (cfg Config) Service() *integram.Service {
 			commentCard,
 			attachFileToCard,
 			afterBoardIntegratedActionSelected,
-			//			afterCardCreatedActionSelected,
 			sendBoardFiltersKeyboard,
 			boardFilterButtonPressed,
+			boardIDFilterButtonPressed,
+			filterRuleBoardSelected,
+			boardForCardSpecSelected,
+			listForCardSpecSelected,
+			standupBoardsButtonPressed,
+			boardOverviewButtonPressed,
+			searchResultPressed,
 			сardDueDateEntered,
+			cardDueDateConfirmed,
 			inlineCardButtonPressed,
 			сardDescEntered,
 			сardNameEntered,
+			checkItemEntered,
+			commentReplyEntered,
 		},
 		TGNewMessageHandler:         newMessageHandler,
 		TGInlineQueryHandler:        inlineQueryHandler,
@@ -120,7 +134,15 @@ func (cfg Config) Service() *integram.Service {
 
 // ChatSettings contains filters information
 type ChatSettings struct {
-	Boards map[string]ChatBoardSetting
+	Boards          map[string]ChatBoardSetting
+	TrelloMembers   []string // Trello IDs of the chat members who authorized the bot
+	Delivery        ChatDeliverySettings
+	Quiet           ChatQuietSettings
+	DueReminders    []int // minutes before the due date to remind, nil means defaultDueReminders
+	DueRemindersOff bool
+	Standup         ChatStandupSettings
+	DefaultBoard    string // board for the cards created with /new, set on the first use
+	DefaultList     string
 }
 
 // UserSettings contains boards data and target chats to deliver notifications
@@ -142,6 +164,8 @@ type ChatBoardFilterSettings struct {
 	Archived       bool
 	Checklisted    bool
 	Due            bool
+	Renamed        bool
+	DescChanged    bool
 }
 
 // ChatBoardSetting contains Trello board settings
@@ -149,9 +173,13 @@ type ChatBoardSetting struct {
 	Name            string // Board name
 	Enabled         bool   // Enable notifications on that board
 	Filter          ChatBoardFilterSettings
-	OAuthToken      string // backward compatibility for some of migrated from v1 users
-	TrelloWebhookID string // backward compatibility for some of migrated from v1 users
-	User            int64  // ID of User who integrate this board into this Chat
+	Lists           ChatBoardIDFilter // Notify only about or ignore the cards in these lists
+	Labels          ChatBoardIDFilter // Notify only about or ignore the cards with these labels
+	Members         ChatBoardIDFilter // Notify only about or ignore the cards assigned to these members
+	Rules           []ChatBoardRule   // Keyword and regexp rules set with /filter
+	OAuthToken      string            // backward compatibility for some of migrated from v1 users
+	TrelloWebhookID string            // backward compatibility for some of migrated from v1 users
+	User            int64             // ID of User who integrate this board into this Chat
 }
 
 // UserBoardSetting contains Trello board settings
@@ -788,6 +816,10 @@ func renderBoardFilters(c *integram.Context, boardID string, keyboard *integram.
 					} else {
 						(*keyboard)[rowIndex][colIndex].Text = "Turn on notifications"
 					}
+				} else if button.Data == "lists" || button.Data == "labels" || button.Data == "members" {
+					if bs.Enabled && boardIDFilter(&bs, button.Data).Active() {
+						(*keyboard)[rowIndex][colIndex].Text = markSign + button.Text
+					}
 				} else {
 					v := reflect.ValueOf(bs.Filter).FieldByName(button.Data)
 					if bs.Enabled && v.IsValid() && v.Bool() {
@@ -820,6 +852,8 @@ func storeCard(c *integram.Context, card *t.Card) {
 			c.Log().WithError(err).Errorf("Cards cache update error")
 		}
 	}
+
+	scheduleDueReminders(c, card)
 }
 
 func getBoardFilterKeyboard(c *integram.Context, boardID string) *integram.Keyboard {
@@ -830,6 +864,8 @@ func getBoardFilterKeyboard(c *integram.Context, boardID string) *integram.Keybo
 		integram.Buttons{{"CardCreated", "Card Created"}, {"CardCommented", "Commented"}, {"CardMoved", "Moved"}},
 		integram.Buttons{{"PersonAssigned", "Someone Assigned"}, {"Labeled", "Label attached"}, {"Voted", "Upvoted"}},
 		integram.Buttons{{"Due", "Due date set"}, {"Checklisted", "Checklisted"}, {"Archived", "Archived"}},
+		integram.Buttons{{"Renamed", "Renamed"}, {"DescChanged", "Description changed"}},
+		integram.Buttons{{"lists", "📁 Lists"}, {"labels", "🏷 Labels"}, {"members", "👤 Members"}},
 	)
 
 	renderBoardFilters(c, boardID, &keyboard)
@@ -877,6 +913,10 @@ func boardFilterButtonPressed(c *integram.Context, boardID string) error {
 			Send()
 	}
 
+	if answer == "lists" || answer == "labels" || answer == "members" {
+		return sendBoardIDFilterKeyboard(c, boardID, answer, 0)
+	}
+
 	cs := chatSettings(c)
 	if bs, ok := cs.Boards[boardID]; ok {
 
@@ -1059,7 +1099,12 @@ func cardText(c *integram.Context, card *t.Card) string {
 		by = m.EncodeEntities(card.MemberCreator.FullName)
 	}
 
-	text += m.EncodeEntities(card.Name) + " " + m.URL("➔", c.WebPreview("by "+by, cardPath(card), "", card.URL(), ""))
+	coverURL := ""
+	if cover := card.Cover(); cover != nil {
+		coverURL = cover.PreviewURL(600)
+	}
+
+	text += m.EncodeEntities(card.Name) + " " + m.URL("➔", c.WebPreview("by "+by, cardPath(card), "", card.URL(), coverURL))
 
 	if card.Desc != "" {
 		// todo: replace markdown in desc with html?
@@ -1094,14 +1139,15 @@ func cardText(c *integram.Context, card *t.Card) string {
 		}
 	}
 
-	if card.Due != nil && !card.Due.IsZero() {
-		text += "\n  📅 " + decent.Relative(card.Due.In(c.User.TzLocation()))
+	if due := dueText(c, card); due != "" {
+		text += "\n  📅 " + due
 	}
 
 	if len(card.Checklists) > 0 {
 		for _, checklist := range card.Checklists {
 			if len(checklist.CheckItems) > 0 {
-				text += "\n  🚩 " + m.Bold(checklist.Name) + "\n"
+				done, total := checklistProgress(checklist)
+				text += "\n  🚩 " + m.Bold(checklist.Name) + fmt.Sprintf(" %d/%d (%d%%)", done, total, done*100/total) + "\n"
 				for _, checkItem := range checklist.CheckItems {
 					if checkItem.State == "incomplete" {
 						text += "       ⬜️ "
@@ -1115,11 +1161,62 @@ func cardText(c *integram.Context, card *t.Card) string {
 		}
 	}
 
-	text += "\n  📁 " + m.Bold(card.List.Name)
+	if rank, total := cardRank(c, card); rank > 0 {
+		text += fmt.Sprintf("\n  📁 #%d of %d in %s", rank, total, m.Bold(card.List.Name))
+	} else {
+		text += "\n  📁 " + m.Bold(card.List.Name)
+	}
 
 	return text
 }
 
+func dueText(c *integram.Context, card *t.Card) string {
+	hasDue := card.Due != nil && !card.Due.IsZero()
+	text := ""
+
+	if card.Start != nil && !card.Start.IsZero() {
+		text = decent.Relative(card.Start.In(c.User.TzLocation()))
+		if !hasDue {
+			return "starts " + text
+		}
+		text += " → "
+	}
+
+	if !hasDue {
+		return ""
+	}
+	text += decent.Relative(card.Due.In(c.User.TzLocation()))
+
+	if card.DueComplete {
+		text += " " + markSign
+	} else if card.DueReminder != nil && *card.DueReminder >= 0 {
+		text += " 🔔 " + dueReminderText(*card.DueReminder)
+	}
+	return strings.TrimSpace(text)
+}
+
+func pluralize(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func dueReminderText(minutes int) string {
+	switch {
+	case minutes < 0:
+		return ""
+	case minutes == 0:
+		return "at the due time"
+	case minutes%(60*24) == 0:
+		return pluralize(minutes/(60*24), "day") + " before"
+	case minutes%60 == 0:
+		return pluralize(minutes/60, "hour") + " before"
+	default:
+		return pluralize(minutes, "minute") + " before"
+	}
+}
+
 func cardInlineKeyboard(card *t.Card, more bool) integram.InlineKeyboard {
 	but := integram.InlineButtons{}
 	but.Append("assign", "Assign")
@@ -1143,13 +1240,11 @@ func cardInlineKeyboard(card *t.Card, more bool) integram.InlineKeyboard {
 
 	but.Append("desc", "Description")
 	but.Append("due", "Due")
-	// 65535 is the Trello default for first card in the list
-	if card.Pos <= 65535 {
-		but.AppendWithState(0, "position", "⬇ Bottom")
-	} else {
-		but.AppendWithState(1, "position", "⬆ Top")
-	}
+	but.Append("position", "↕ Position")
 	but.Append("label", "Label")
+	but.Append("checklist", "Checklist")
+	but.Append("comments", "💬 Comments")
+	but.Append("history", "🕘 History")
 	if !card.Closed {
 		but.AppendWithState(1, "archive", "Archive")
 	} else {
@@ -1160,6 +1255,32 @@ func cardInlineKeyboard(card *t.Card, more bool) integram.InlineKeyboard {
 	return but.Markup(3, "actions")
 }
 
+// cardCreatedKeyboard is the follow-up wizard shown under the just created card
+func cardCreatedKeyboard() integram.InlineKeyboard {
+	but := integram.InlineButtons{}
+	but.Append("assign", "👤 Assign")
+	but.Append("label", "🏷 Labels")
+	but.Append("due", "📅 Due date")
+	but.Append("back", "✅ Done")
+	return but.Markup(3, "created")
+}
+
+// backButtonData returns the data of the back button to return to the created card wizard if it was opened from there
+func backButtonData(c *integram.Context) string {
+	kb := c.Callback.Message.InlineKeyboardMarkup
+	if kb.State == "created" {
+		return "created"
+	}
+	for _, row := range kb.Buttons {
+		for _, button := range row {
+			if button.Data == "created" {
+				return "created"
+			}
+		}
+	}
+	return "back"
+}
+
 func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 	log.WithField("data", c.Callback.Data).WithField("state", c.Callback.State).WithField("cardID", cardID).Debug("inlineCardButtonPressed")
 	api := api(c)
@@ -1183,6 +1304,12 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 	if err != nil {
 		return err
 	}
+	rememberChatMember(c)
+
+	if state := c.Callback.Message.InlineKeyboardMarkup.State; state == "move" && c.Callback.Data == "board" ||
+		state == "move_board" && c.Callback.Data != "move" || strings.HasPrefix(state, "mb_") {
+		return moveToBoardButtonPressed(c, api, card)
+	}
 
 	if c.Callback.Message.InlineKeyboardMarkup.State == "move" {
 		err := moveCard(c, api, c.Callback.Data, card)
@@ -1192,7 +1319,7 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 		c.Callback.Data = "back"
 	}
 
-	if c.Callback.Message.InlineKeyboardMarkup.State == "assign" && c.Callback.Data != "back" {
+	if c.Callback.Message.InlineKeyboardMarkup.State == "assign" && c.Callback.Data != "back" && c.Callback.Data != "created" {
 		log.Info("assign state ", c.Callback.State)
 		unassign := false
 
@@ -1216,7 +1343,7 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 			return err
 		}
 	}
-	if c.Callback.Message.InlineKeyboardMarkup.State == "label" && c.Callback.Data != "back" {
+	if c.Callback.Message.InlineKeyboardMarkup.State == "label" && c.Callback.Data != "back" && c.Callback.Data != "created" {
 		log.Info("label state ", c.Callback.State)
 		removeLabel := false
 
@@ -1241,7 +1368,7 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 
 	if c.Callback.Message.InlineKeyboardMarkup.State == "due" {
 		if c.Callback.Data == "due_clear" {
-			_, err := cardSetDue(c, card, "")
+			_, err := cardSetDue(c, card, time.Time{})
 			if err != nil {
 				return err
 			}
@@ -1258,25 +1385,46 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 				msg.SetReplyToMsgID(c.Message.MsgID)
 			}
 
-			err = msg.SetText(c.User.Mention()+" write the due date in the format `dd.MM hh:mm`").
+			err = msg.SetText(c.User.Mention()+" write the due date, "+dueDateHelp).
 				EnableForceReply().
 				EnableHTML().
 				SetSelective(true).
 				SetKeyboard(integram.Button{"cancel", "Cancel"}, true).
+				SetOneTimeKeyboard(true).
 				SetReplyAction(сardDueDateEntered, card).
 				Send()
 			if err != nil {
 				return err
 			}
-		} else if c.Callback.Data != "back" {
-			_, err := cardSetDue(c, card, c.Callback.Data)
+		} else if c.Callback.Data != "back" && c.Callback.Data != "created" {
+			dt, err := parseUserDueDate(c, c.Callback.Data)
+			if err != nil {
+				return err
+			}
+			_, err = cardSetDue(c, card, dt)
 			if err != nil {
 				return err
 			}
 		}
 
-		c.Callback.Data = "back"
+		c.Callback.Data = backButtonData(c)
+
+	}
 
+	if state := c.Callback.Message.InlineKeyboardMarkup.State; c.Callback.Data != "back" && c.Callback.Data != "created" {
+		if strings.HasPrefix(state, "cl_") {
+			return checklistButtonPressed(c, card, strings.TrimPrefix(state, "cl_"))
+		}
+		if state == "position" || state == "pos_after" {
+			return cardPositionButtonPressed(c, api, card)
+		}
+		if state == "comments" || state == "history" {
+			return cardActivityButtonPressed(c, card, state)
+		}
+		if state == "checklists" {
+			c.AnswerCallbackQuery("", false)
+			return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), checklistKeyboard(card, checklistByID(card, c.Callback.Data), 0, backButtonData(c)))
+		}
 	}
 
 	switch c.Callback.Data {
@@ -1284,6 +1432,12 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 		kb := cardInlineKeyboard(card, false)
 
 		return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), kb)
+	case "created":
+		return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), cardCreatedKeyboard())
+	case "checklist":
+		return openChecklist(c, card)
+	case "comments", "history":
+		return openCardActivity(c, card, c.Callback.Data, 0)
 	case "more":
 		kb := cardInlineKeyboard(card, true)
 
@@ -1314,27 +1468,7 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 		return c.EditPressedInlineButton(1, "Archive")
 
 	case "position":
-
-		if c.Callback.State == 1 {
-			err = card.SetPosition("top")
-		} else {
-			err = card.SetPosition("bottom")
-		}
-
-		if err != nil {
-			return err
-		}
-
-		c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.pos": card.Pos}}, card)
-		log.Infof("new card pos %v", card.Pos)
-		if card.Pos <= 65535 {
-			c.AnswerCallbackQuery("You moved card \""+card.Name+"\" to the top of the list", false)
-
-			return c.EditPressedInlineButton(0, "⬇ To the bottom")
-		}
-		c.AnswerCallbackQuery("You moved card \""+card.Name+"\" to the bottom of the list", false)
-
-		return c.EditPressedInlineButton(1, "⬆ To the top")
+		return openCardPosition(c, api, card)
 	case "due":
 		buts := integram.InlineButtons{}
 
@@ -1354,7 +1488,7 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 		buts.Append(t.EndOfMonth().Format(dueDateFormat), "End of this month")
 		buts.Append(now.New(t.AddDate(0, 1, -1*t.Day()+3)).EndOfMonth().Format(dueDateFormat), "End of the next month")
 		buts.Append("due_manual", "Enter the date")
-		buts.Append("back", "← Back")
+		buts.Append(backButtonData(c), "← Back")
 
 		return c.EditPressedMessageTextAndInlineKeyboard(cardText(c, card), buts.Markup(1, "due"))
 
@@ -1371,33 +1505,34 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 				buts.Append(list.Id, list.Name)
 			}
 		}
+		buts.Append("board", "📋 Move to board…")
 		buts.Append("back", "↑ Less")
 
-		return c.EditInlineKeyboard(c.Callback.Message, "actions", buts.Markup(1, "move"))
+		return c.EditInlineKeyboard(c.Callback.Message, c.Callback.Message.InlineKeyboardMarkup.State, buts.Markup(1, "move"))
 	case "label":
 		buts, err := getCardLabelsButtons(c, api, card)
 		if err != nil {
 			return err
 		}
 
-		buts.Append("back", "← Back")
+		buts.Append(backButtonData(c), "← Back")
 
 		// c.Callback.Message.SetCallbackAction(inlineCardAssignButtonPressed, cardID)
 
 		kb := buts.Markup(1, "label")
 		kb.FixedWidth = true
-		return c.EditInlineKeyboard(c.Callback.Message, "actions", kb)
+		return c.EditInlineKeyboard(c.Callback.Message, c.Callback.Message.InlineKeyboardMarkup.State, kb)
 	case "assign":
 		buts, err := getCardAssignButtons(c, api, card)
 		if err != nil {
 			return err
 		}
 
-		buts.Append("back", "← Back")
+		buts.Append(backButtonData(c), "← Back")
 
 		kb := buts.Markup(1, "assign")
 		kb.FixedWidth = true
-		return c.EditInlineKeyboard(c.Callback.Message, "actions", kb)
+		return c.EditInlineKeyboard(c.Callback.Message, c.Callback.Message.InlineKeyboardMarkup.State, kb)
 	case "vote":
 		me, err := me(c, api)
 		if err != nil {
@@ -1478,44 +1613,13 @@ func inlineCardButtonPressed(c *integram.Context, cardID string) error {
 	return nil
 }
 
-/*func getCardActionButtons(c *integram.Context, api *t.Client, card *t.Card) (integram.InlineButtons, error) {
-	but := integram.InlineButtons{}
-
-	members, err := membersByBoardID(c, api, card.Board.Id)
-	// TODO: optimisation needed for double requesting members
-	if err != nil {
-		return but, err
-	}
-
-	if len(members) <= 3 {
-		but, _ = getCardAssignButtons(c, api, card)
-	} else {
-		but.Append("assign", "Assign someone")
-	}
-	if card.Pos == 1 {
-		but.AppendWithState(0, "position", "⬇️ To the bottom")
-	} else {
-		but.AppendWithState(1, "position", "⬆️ To the top")
-	}
-
-	but.Append("due", "📅 Set due date")
-	but.Append("done", "✅ Done")
-	return but, nil
-}*/
-
 func textForCardEntered(c *integram.Context, boardID string, boardName string, listID string, listName string) error {
-	api := api(c)
-	_, err := api.CreateCard(c.Message.Text, listID, nil)
-
-	if t.IsBadToken(err) {
-		c.User.ResetOAuthToken()
-	}
-
+	card, unresolved, err := createCardFromSpec(c, boardID, listID, parseCardSpec(c.Message.Text))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return sendCreatedCard(c, card, unresolved)
 }
 
 func getCard(c *integram.Context, api *t.Client, cardID string) (*t.Card, error) {
@@ -1621,13 +1725,12 @@ func getCardLabelsButtons(c *integram.Context, api *t.Client, card *t.Card) (int
 	return but, err
 }
 
-func cardSetDue(c *integram.Context, card *t.Card, date string) (string, error) {
+// cardSetDue sets the due date of the card, zero dt clears it
+func cardSetDue(c *integram.Context, card *t.Card, dt time.Time) (string, error) {
 	api := api(c)
 	var err error
-	var dt time.Time
-	n := time.Now()
 
-	if date == "" {
+	if dt.IsZero() {
 		_, err = api.Request("PUT", "cards/"+card.Id+"/due", nil, url.Values{"value": {"null"}})
 		if t.IsBadToken(err) {
 			c.User.ResetOAuthToken()
@@ -1642,18 +1745,8 @@ func cardSetDue(c *integram.Context, card *t.Card, date string) (string, error)
 
 	}
 
-	dt, err = time.ParseInLocation(dueDateFullFormat, date[0:5]+"."+fmt.Sprintf("%d", int(n.Year()))+date[5:], c.User.TzLocation())
-
-	if err != nil {
-		return "", err
-	}
-
 	dt = dt.In(time.UTC)
 
-	if int(dt.Month()) < int(n.Month()) {
-		dt = dt.AddDate(1, 0, 0)
-	}
-
 	log.WithField("due", dt.Format(time.RFC3339Nano)).Info("set due date")
 
 	_, err = api.Request("PUT", "cards/"+card.Id+"/due", nil, url.Values{"value": {dt.Format(time.RFC3339Nano)}})
@@ -1665,6 +1758,7 @@ func cardSetDue(c *integram.Context, card *t.Card, date string) (string, error)
 		return "", err
 	}
 
+	card.Due = &dt
 	err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.due": &dt}}, card)
 	return dt.In(c.User.TzLocation()).Format(time.RFC1123Z), err
 }
@@ -1710,112 +1804,45 @@ func сardDueDateEntered(c *integram.Context, card *t.Card) error {
 		action = c.Message.Text
 	}
 
-	_, err := cardSetDue(c, card, action)
-	if err == nil {
-		c.NewMessage().SetText("Ok").HideKeyboard().Send()
-	}
-
-	return err
-}
-
-/*func afterCardCreatedActionSelected(c *integram.Context, card *t.Card) error {
-	fmt.Printf("afterCardCreatedActionSelected card:%+v\n", card)
-	api := api(c)
-	action, _ := c.KeyboardAnswer()
-	var err error
-	switch action {
-	case "movebottom":
-
-		_, err = api.Request("PUT", "cards/"+card.Id+"/pos", nil, url.Values{"value": {"bottom"}})
-		if err != nil {
-			return err
-		}
-
-		card.Pos = 999
-		but, _ := getCardActionButtons(c, api, card)
-
-		c.NewMessage().
-			SetText("Now the card is at the bottom").
-			SetKeyboard(but.Markup(3), false).
-			SetReplyAction(afterCardCreatedActionSelected, card).
-			Send()
-	case "movetop":
-		_, err = api.Request("PUT", "cards/"+card.Id+"/pos", nil, url.Values{"value": {"top"}})
-		if err != nil {
-			return err
-		}
-
-		card.Pos = 1
-		but, _ := getCardActionButtons(c, api, card)
-
-		c.NewMessage().
-			SetKeyboard(but.Markup(3), false).
-			SetText("Now the card is on the top").
-			SetReplyAction(afterCardCreatedActionSelected, card).
-			Send()
-	case "assign":
-		buttons, err := getCardAssignButtons(c, api, card)
-		if err != nil {
-			return err
-		}
-		c.NewMessage().
-			SetText("Select person to assign").
-			SetKeyboard(buttons.Markup(3), true).
-			SetReplyAction(afterCardCreatedActionSelected, card).
-			Send()
-	case "due":
-		buttons := integram.Buttons{}
-
-		if card.Due != nil && !card.Due.IsZero() {
-			buttons.Append("due_clear", "Clear the due date")
-		}
-		userLocation := c.User.TzLocation()
-		t := now.New(time.Now().In(userLocation))
-
-		buttons.Append(t.EndOfDay().Format(dueDateFormat), "🔥 Today")
-		buttons.Append(t.EndOfDay().AddDate(0, 0, 1).Format(dueDateFormat), "Tomorrow")
-
-		buttons.Append(t.EndOfSunday().Format(dueDateFormat), "Sunday")
-		buttons.Append(t.EndOfSunday().AddDate(0, 0, 7).Format(dueDateFormat), "Next Sunday")
-
-		buttons.Append(t.EndOfMonth().Format(dueDateFormat), "End of this month")
-		buttons.Append(now.New(t.AddDate(0, 1, -1*t.Day()+3)).EndOfMonth().Format(dueDateFormat), "End of the next month")
-
-		c.NewMessage().
-			SetText("Select the due date or write in the format dd.MM hh:mm").
-			SetKeyboard(buttons.Markup(3), true).
+	dt, err := parseUserDueDate(c, action)
+	if err != nil {
+		return c.NewMessage().
+			SetReplyToMsgID(c.Message.MsgID).
+			SetText(m.EncodeEntities(err.Error())+"\nPlease write the due date again, "+dueDateHelp).
+			EnableHTML().
+			EnableForceReply().
+			SetSelective(true).
 			SetReplyAction(сardDueDateEntered, card).
 			Send()
+	}
 
-	case "done":
-		c.NewMessage().
-			HideKeyboard().
-			SetText("Ok!").
-			Send()
-	default:
-		member, unassigned, err := assignMemberID(c, api, action, false, card)
-		if err != nil {
-			return err
-		}
+	kb := integram.InlineButtons{}
+	kb.Append("confirm", "✅ Confirm")
+	kb.Append("cancel", "✖️ Cancel")
 
-		var msgText string
-		if unassigned {
-			msgText = member.FullName + " unassigned"
-		} else {
-			msgText = member.FullName + " assigned"
-		}
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(fmt.Sprintf("Set the due date of %s to %s?", m.Bold(card.Name), m.Bold(dueDatePreview(c, dt)))).
+		EnableHTML().
+		SetInlineKeyboard(kb.Markup(2, "")).
+		SetCallbackAction(cardDueDateConfirmed, card, dt).
+		Send()
+}
 
-		but, _ := getCardActionButtons(c, api, card)
+func cardDueDateConfirmed(c *integram.Context, card *t.Card, dt time.Time) error {
+	if c.Callback.Data != "confirm" {
+		c.AnswerCallbackQuery("", false)
+		return c.EditPressedMessageText("Ok, the due date is not changed")
+	}
 
-		c.NewMessage().
-			SetKeyboard(but.Markup(3), false).
-			SetText(msgText).
-			SetReplyAction(afterCardCreatedActionSelected, card).
-			Send()
+	_, err := cardSetDue(c, card, dt)
+	if err != nil {
+		return err
 	}
 
-	return err
-}*/
+	c.AnswerCallbackQuery("", false)
+	return c.EditPressedMessageText("📅 Due date of " + m.Bold(card.Name) + " is set to " + m.Bold(dueDatePreview(c, dt)))
+}
 
 func multipartBody(params url.Values, paramName, path string) (b *bytes.Buffer, contentType string, err error) {
 	file, err := os.Open(path)
@@ -1982,7 +2009,7 @@ func assignMemberID(c *integram.Context, api *t.Client, memberID string, unassig
 			}
 
 		} else {
-			err = fmt.Errorf("can't find memberID inside board %f", card.Board.Id)
+			err = fmt.Errorf("can't find memberID inside board %s", card.Board.Id)
 		}
 		// looks like member ID
 	} else {
@@ -2048,12 +2075,15 @@ func sendBoardsToIntegrate(c *integram.Context) error {
 
 func inlineCardCreate(c *integram.Context, listID string) error {
 	api := api(c)
-	card, err := api.CreateCard(c.ChosenInlineResult.Query, listID, nil)
-
+	list, err := api.List(listID)
 	if t.IsBadToken(err) {
 		c.User.ResetOAuthToken()
 	}
+	if err != nil {
+		return err
+	}
 
+	card, _, err := createCardFromSpec(c, list.IdBoard, listID, parseCardSpec(c.ChosenInlineResult.Query))
 	if err != nil {
 		return err
 	}
@@ -2077,7 +2107,7 @@ func inlineCardCreate(c *integram.Context, listID string) error {
 	if err != nil {
 		return err
 	}
-	list := listsFilterByID(lists, card.IdList)
+	list = listsFilterByID(lists, card.IdList)
 	board := boardsFilterByID(boards, card.IdBoard)
 	member, _ := me(c, api)
 
@@ -2087,7 +2117,7 @@ func inlineCardCreate(c *integram.Context, listID string) error {
 
 	storeCard(c, card)
 
-	return c.EditMessageTextAndInlineKeyboard(c.ChosenInlineResult.Message, "", cardText(c, card), cardInlineKeyboard(card, false))
+	return c.EditMessageTextAndInlineKeyboard(c.ChosenInlineResult.Message, "", cardText(c, card), cardCreatedKeyboard())
 }
 
 func inlineGetExistingCard(c *integram.Context, cardID string) error {
@@ -2131,7 +2161,7 @@ func cacheAllCards(c *integram.Context, boards []*t.Board) error {
 		board := boards[bi]
 
 		var bcards []*t.Card
-		b, err := api.Request("GET", "boards/"+board.Id+"/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,idMembers,idMembersVoted,pos,due,idBoard,idList,dateLastActivity"}})
+		b, err := api.Request("GET", "boards/"+board.Id+"/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,idMembers,idMembersVoted,pos,due,dueComplete,start,idBoard,idList,dateLastActivity"}})
 
 		if t.IsBadToken(err) {
 			c.User.ResetOAuthToken()
@@ -2185,7 +2215,7 @@ func inlineQueryHandler(c *integram.Context) error {
 
 	if cards == nil {
 
-		b, err := api.Request("GET", "members/me/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,idMembers,idMembersVoted,pos,due,idBoard,idList,dateLastActivity"}})
+		b, err := api.Request("GET", "members/me/cards", nil, url.Values{"filter": {"open"}, "fields": {"name,idMembers,idMembersVoted,pos,due,dueComplete,start,idBoard,idList,dateLastActivity"}})
 
 		if t.IsBadToken(err) {
 			c.User.ResetOAuthToken()
@@ -2297,6 +2327,7 @@ func inlineQueryHandler(c *integram.Context) error {
 		return c.AnswerInlineQueryWithResults(res, 60, true, nextOffset)
 	}
 
+	spec := parseCardSpec(c.InlineQuery.Query)
 	for bi := 0; bi < len(boards) && bi < 10 && total < 20; bi++ {
 		lists, err := listsByBoardID(c, api, boards[bi].Id)
 
@@ -2311,12 +2342,12 @@ func inlineQueryHandler(c *integram.Context) error {
 						ID:          "l_" + lists[li].Id,
 						Type:        "article",
 						Title:       lists[li].Name + " • " + boards[bi].Name,
-						Description: c.InlineQuery.Query,
+						Description: spec.Name,
 						ThumbURL:    "https://st.integram.org/trello/new_" + boards[bi].Prefs.Background + ".png",
 						InputMessageContent: tg.InputTextMessageContent{
 							ParseMode:             "HTML",
 							DisableWebPagePreview: false,
-							Text:                  c.InlineQuery.Query + "\n\n<b>" + lists[li].Name + " • " + boards[bi].Name + "</b>",
+							Text:                  m.EncodeEntities(spec.Name) + "\n\n<b>" + lists[li].Name + " • " + boards[bi].Name + "</b>",
 						},
 						ReplyMarkup: &tg.InlineKeyboardMarkup{
 							InlineKeyboard: [][]tg.InlineKeyboardButton{
@@ -2336,6 +2367,9 @@ func newMessageHandler(c *integram.Context) error {
 	u, _ := iurl.Parse("https://trello.com")
 	c.ServiceBaseURL = *u
 
+	rememberChatMember(c)
+	rememberChatMessage(c)
+
 	command, param := c.Message.GetCommand()
 
 	if param == "silent" {
@@ -2348,7 +2382,9 @@ func newMessageHandler(c *integram.Context) error {
 	switch command {
 	case "new":
 		var err error
-		if c.User.OAuthValid() {
+		if c.User.OAuthValid() && param != "" {
+			err = newCardCommand(c, param)
+		} else if c.User.OAuthValid() {
 			_, err = c.Service().DoJob(sendBoardsForCard, c)
 		} else {
 			kb := integram.InlineKeyboard{}
@@ -2357,11 +2393,23 @@ func newMessageHandler(c *integram.Context) error {
 			err = c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("You need to auth me to be able to create cards").SetInlineKeyboard(kb).Send()
 		}
 		return err
+	case "card":
+		if c.User.OAuthValid() {
+			return cardFromMessageCommand(c, param)
+		}
+		kb := integram.InlineKeyboard{}
+		kb.AddPMSwitchButton(c.Bot(), "👉  Tap me to auth", "auth")
+
+		return c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("You need to auth me to be able to create cards").SetInlineKeyboard(kb).Send()
 	case "search":
+		if param != "" {
+			return searchCommand(c, param)
+		}
+
 		var err error
 		kb := integram.InlineButtons{integram.InlineButton{Text: "Tap to see how it's works", SwitchInlineQuery: "bug"}}
 
-		err = c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("To search and share cards just type in any chat " + m.Bold("@"+c.Bot().Username+" fragment of card's name")).EnableHTML().SetInlineKeyboard(kb.Markup(3, "")).Send()
+		err = c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("To search and share cards just type in any chat " + m.Bold("@"+c.Bot().Username+" fragment of card's name") + "\n\n" + searchCommandHelp).EnableHTML().SetInlineKeyboard(kb.Markup(3, "")).Send()
 
 		return err
 	case "start":
@@ -2377,6 +2425,10 @@ func newMessageHandler(c *integram.Context) error {
 				}
 			}
 		}*/
+		if param == "mytasks" {
+			return myTasksCommand(c)
+		}
+
 		if param == "auth" {
 			if c.User.OAuthValid() {
 				return c.NewMessage().SetText("You are already authed at Trello. You can help another members of your group to do this and use the full power of the Trello inside the Telegram").Send()
@@ -2441,8 +2493,24 @@ func newMessageHandler(c *integram.Context) error {
 			SetTextFmt("Open this link to authorize me: %s", oauthRedirectURL(c)).
 			SetChat(c.User.ID).
 			Send()
+	case "filter":
+		return filterCommand(c, param)
+	case "digest":
+		return digestCommand(c, param)
+	case "quiet":
+		return quietCommand(c, param)
+	case "reminders":
+		return remindersCommand(c, param)
+	case "standup":
+		return standupCommand(c, param)
+	case "mytasks":
+		return myTasksCommand(c)
+	case "board":
+		return boardCommand(c, param)
 	case "cancel", "clean", "reset":
 		return c.NewMessage().SetText("Clean").HideKeyboard().Send()
+	case "":
+		return unfurlTrelloLinks(c)
 	}
 	return nil
 }
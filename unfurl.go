@@ -0,0 +1,101 @@
+package trello
+
+import (
+	"strings"
+	"time"
+
+	t "github.com/mohsenasm/integram-trello/api"
+	"github.com/requilence/integram"
+)
+
+// max Trello links unfurled from the single message
+const unfurlLinksLimit = 3
+
+// unfurlTrelloLinks replies to the message with the cards and boards linked in it.
+// Links are fetched on behalf of the sender, so nothing happens until they authorize the bot
+func unfurlTrelloLinks(c *integram.Context) error {
+	if !c.User.OAuthValid() {
+		return nil
+	}
+
+	text := c.Message.Text
+	if text == "" {
+		text = c.Message.Caption
+	}
+	if strings.HasPrefix(text, "/") {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, match := range trelloLinkRe.FindAllStringSubmatch(text, -1) {
+		if seen[match[2]] {
+			continue
+		}
+		seen[match[2]] = true
+		if len(seen) > unfurlLinksLimit {
+			break
+		}
+
+		var err error
+		if match[1] == "c" {
+			err = unfurlCard(c, match[2])
+		} else {
+			err = unfurlBoard(c, match[2])
+		}
+		if err != nil {
+			c.Log().WithError(err).WithField("link", match[0]).Error("can't unfurl the Trello link")
+		}
+	}
+	return nil
+}
+
+func unfurlCard(c *integram.Context, shortLink string) error {
+	api := api(c)
+	card, err := api.Card(shortLink)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.SetServiceCache("card_"+card.Id, card, time.Hour*24*100)
+	if err != nil {
+		return err
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(cardText(c, card)).
+		AddEventID("card_"+card.Id).
+		EnableHTML().
+		DisableWebPreview().
+		SetReplyAction(cardReplied, card.Id).
+		SetInlineKeyboard(cardInlineKeyboard(card, false)).
+		SetCallbackAction(inlineCardButtonPressed, card.Id).
+		Send()
+}
+
+func unfurlBoard(c *integram.Context, shortLink string) error {
+	board, err := api(c).Board(shortLink)
+	if t.IsBadToken(err) {
+		c.User.ResetOAuthToken()
+	}
+	if err != nil {
+		return err
+	}
+
+	text, kb, err := boardOverview(c, board.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.NewMessage().
+		SetReplyToMsgID(c.Message.MsgID).
+		SetText(text).
+		EnableHTML().
+		DisableWebPreview().
+		SetInlineKeyboard(kb).
+		SetCallbackAction(boardOverviewButtonPressed, board.Id).
+		Send()
+}
@@ -77,15 +77,7 @@ type actionData struct {
 	Board       t.Board
 	BoardSource *t.Board
 
-	Old *struct {
-		Name   string
-		ID     string
-		text   string
-		IDList string
-		Closed bool
-		Due    *time.Time
-		Desc   string
-	}
+	Old *oldCard
 
 	Organization *struct {
 		Name string
@@ -93,6 +85,47 @@ type actionData struct {
 	}
 }
 
+// oldCard contains the previous values of the card fields changed by the updateCard action
+type oldCard struct {
+	Name        string
+	ID          string
+	text        string
+	IDList      string
+	Closed      bool
+	Due         *time.Time
+	DueComplete bool
+	DueReminder *int
+	Start       *time.Time
+	Desc        string
+
+	fields map[string]bool
+}
+
+// UnmarshalJSON also remembers the set of received fields to distinguish the removed (null) values from the absent ones
+func (o *oldCard) UnmarshalJSON(data []byte) error {
+	type plain oldCard
+	err := json.Unmarshal(data, (*plain)(o))
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	o.fields = make(map[string]bool, len(raw))
+	for field := range raw {
+		o.fields[field] = true
+	}
+	return nil
+}
+
+func (o *oldCard) has(field string) bool {
+	return o != nil && o.fields[field]
+}
+
 type member struct {
 	ID         string
 	AvatarHash string
@@ -177,9 +210,12 @@ func cardPath(card *t.Card) (path string) {
 	return
 }
 
+// updateCardMessages refreshes the card messages showing the card's actions or the keyboard of the just created card
 func updateCardMessages(c *integram.Context, request *integram.WebhookContext, card *t.Card) {
 	if request.FirstParse() {
-		c.EditMessagesWithEventID("card_"+card.Id, "actions", cardText(c, card), cardInlineKeyboard(card, false))
+		text := cardText(c, card)
+		c.EditMessagesWithEventID("card_"+card.Id, "actions", text, cardInlineKeyboard(card, false))
+		c.EditMessagesWithEventID("card_"+card.Id, "created", text, cardCreatedKeyboard())
 	}
 }
 
@@ -198,6 +234,59 @@ func cleanDesc(desc string) string {
 	return m.EncodeEntities(strings.Trim(a[0], "\n\t\r "))
 }
 
+// maxDescDiffLen keeps the description diff well below the Telegram message limit of 4096 chars
+const maxDescDiffLen = 3000
+
+// descDiff returns the compact line-based diff between old and new description.
+// Removed lines are prefixed with "- ", added lines with "+ ", unchanged lines are omitted
+func descDiff(oldDesc, newDesc string) string {
+	a := strings.Split(strings.Trim(oldDesc, "\n\t\r "), "\n")
+	b := strings.Split(strings.Trim(newDesc, "\n\t\r "), "\n")
+
+	// lcs[i][j] contains the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			if strings.TrimSpace(b[j]) != "" {
+				lines = append(lines, "+ "+b[j])
+			}
+			j++
+		default:
+			if strings.TrimSpace(a[i]) != "" {
+				lines = append(lines, "- "+a[i])
+			}
+			i++
+		}
+	}
+
+	diff := strings.Join(lines, "\n")
+	if len([]rune(diff)) > maxDescDiffLen {
+		diff = string([]rune(diff)[:maxDescDiffLen]) + "…"
+	}
+	return diff
+}
+
 func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error) {
 	u, _ := iurl.Parse("https://trello.com")
 	c.ServiceBaseURL = *u
@@ -226,7 +315,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 	e := false
 
 	if exists := c.Chat.Cache("action_"+wh.Action.ID, &e); exists && e {
-		c.Log().Errorf("duplicate trello webhook %s, request %s, action %s, chat %s", wc.HookID(), wc.RequestID(), wh.Action.ID, c.Chat.ID)
+		c.Log().Errorf("duplicate trello webhook %s, request %s, action %s, chat %d", wc.HookID(), wc.RequestID(), wh.Action.ID, c.Chat.ID)
 		return
 	}
 
@@ -234,7 +323,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 
 	// if this action is produced inside the TG itself – ignore webhook (f.e. reply to comment)
 	if tm, _ := c.FindMessageByEventID("action_" + wh.Action.ID); tm != nil {
-		c.Log().Errorf("duplicate trello webhook %s, request %s, action %s, chat %s", wc.HookID(), wc.RequestID(), wh.Action.ID, c.Chat.ID)
+		c.Log().Errorf("duplicate trello webhook %s, request %s, action %s, chat %d", wc.HookID(), wc.RequestID(), wh.Action.ID, c.Chat.ID)
 		return
 	}
 
@@ -263,6 +352,31 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			card.Board = dbCard.Board
 			card.MemberCreator = dbCard.MemberCreator
 			card.Checklists = dbCard.Checklists
+			card.Labels = dbCard.Labels
+			card.Members = dbCard.Members
+			card.IdMembers = dbCard.IdMembers
+
+			// updateCard action contains only the changed fields
+			old := wh.Action.Data.Old
+			if !old.has("due") {
+				card.Due = dbCard.Due
+			}
+			if !old.has("dueComplete") {
+				card.DueComplete = dbCard.DueComplete
+			}
+			if !old.has("dueReminder") {
+				card.DueReminder = dbCard.DueReminder
+			}
+			if !old.has("start") {
+				card.Start = dbCard.Start
+			}
+			if !old.has("desc") {
+				card.Desc = dbCard.Desc
+			}
+			if !old.has("idAttachmentCover") {
+				card.IdAttachmentCover = dbCard.IdAttachmentCover
+				card.Attachments = dbCard.Attachments
+			}
 		} else {
 			storeCard(c, card)
 		}
@@ -284,6 +398,29 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 		card.Board = &t.Board{Id: wh.Model.ID, Name: wh.Model.Name, ShortUrl: wh.Model.ShortURL, Closed: wh.Model.Closed}
 	}
 
+	if wc.FirstParse() {
+		refreshListOrder(c, wh.Action)
+	}
+
+	// chat's card filters only mute the notifications, the cache and card messages are still updated
+	filtered := !bs.allowsCard(card, wh, cs.TrelloMembers) || !bs.allowsAction(wh, card)
+
+	// in the digest mode or queued quiet hours notifications are buffered and sent later as a summary.
+	// The summary has only created, moved, completed cards and comments, so in quiet hours the rest are sent silently
+	if !filtered {
+		quiet := cs.Quiet.Active(time.Now())
+		if cs.Delivery.Mode != deliveryInstant || quiet && cs.Quiet.Queue && digestEventKind(wh, card, bs.Filter) != "" {
+			bufferDigestEvent(c, wh, card, cs)
+			filtered = true
+		} else if quiet {
+			msg.SetSilent(true)
+		}
+	}
+
+	if wc.FirstParse() {
+		scheduleMyTasksRefresh(c, wh, card)
+	}
+
 	// Maybe we need to update existing message?
 	cardMsg, _ := cardMessage(c, card.Id)
 	cardMsgJustPosted := false
@@ -303,7 +440,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 		c.User.SetCache("boards", nil, time.Second)
 	case "copyCard":
 
-		if !bs.Filter.CardCreated {
+		if !bs.Filter.CardCreated || filtered {
 			return
 		}
 		api := api(c)
@@ -354,7 +491,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			}
 		}
 
-		if !bs.Filter.Labeled {
+		if !bs.Filter.Labeled || filtered {
 			return
 		}
 		msg.SetTextFmt("%s %s label %s %s ", mention(c, byMember), a, colorEmoji(wh.Action.Data.Label.Color), m.Bold(wh.Action.Data.Label.Name))
@@ -364,11 +501,13 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 		card.Pos = float64(wh.Action.Date.Unix())
 
 		storeCard(c, card)
-		if !bs.Filter.CardCreated {
+		if !bs.Filter.CardCreated || filtered {
 			return
 		}
 
-		if cardMsgJustPosted {
+		// the card created from this chat is already posted there
+		createdHere := false
+		if cardMsgJustPosted || cardMsg != nil || c.Chat.Cache("card_created_"+card.Id, &createdHere) && createdHere {
 			return
 		}
 
@@ -381,7 +520,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			Send()
 
 	case "commentCard":
-		if !bs.Filter.CardCommented {
+		if !bs.Filter.CardCommented || filtered {
 			return
 		}
 
@@ -416,7 +555,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			return err
 		}
 
-		if !bs.Filter.Checklisted {
+		if !bs.Filter.Checklisted || filtered {
 			return nil
 		}
 
@@ -478,7 +617,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			return
 		}
 
-		if !bs.Filter.Checklisted {
+		if !bs.Filter.Checklisted || filtered {
 			return
 		}
 
@@ -519,7 +658,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			}
 		}
 
-		if !bs.Filter.PersonAssigned {
+		if !bs.Filter.PersonAssigned || filtered {
 			return
 		}
 		msg.SetTextFmt("%s %s %s", mention(c, byMember), a, mention(c, wh.Action.Member))
@@ -544,6 +683,9 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			return err
 		}
 	case "addAttachmentToCard":
+		if filtered {
+			return
+		}
 		replyTo := 0
 		if cardMsg != nil {
 			replyTo = cardMsg.MsgID
@@ -553,8 +695,22 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			_, err = c.Service().DoJob(downloadAttachment, c, card.Id, replyTo, "by "+mention(c, byMember), wh.Action.Data.Attachment)
 			return err
 		}
-		msg.SetTextFmt("%s attached the link %s", mention(c, byMember), wh.Action.Data.Attachment.URL)
-		// todo: reuse fileid in multichat webhooks
+		msg.AddEventID("attachment_"+wh.Action.Data.Attachment.ID).
+			SetTextFmt("🔗 %s attached %s", mention(c, byMember), linkAttachmentText(c, wh.Action.Data.Attachment.URL, wh.Action.Data.Attachment.Name))
+	case "deleteAttachmentFromCard":
+		if wh.Action.Data.Attachment == nil {
+			return
+		}
+		// remove the mirrored file or link message in all chats
+		if wc.FirstParse() {
+			c.DeleteMessagesWithEventID("attachment_" + wh.Action.Data.Attachment.ID)
+		}
+		if filtered {
+			return
+		}
+
+		msg.SetSilent(true).
+			SetTextFmt("🗑 %s deleted the attachment %s", mention(c, byMember), m.Bold(wh.Action.Data.Attachment.Name))
 	case "updateCard":
 
 		oldCard := wh.Action.Data.Old
@@ -570,7 +726,7 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			if cardMsgJustPosted && err == nil {
 				return
 			}
-			if !bs.Filter.CardMoved {
+			if !bs.Filter.CardMoved || filtered {
 				return
 			}
 			msg.EnableHTML()
@@ -579,16 +735,21 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 			// card renamed
 			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.name": card.Name}}, card)
 			updateCardMessages(c, wc, card)
-
-			return
-
+			if cardMsgJustPosted && err == nil {
+				return
+			}
+			if !bs.Filter.Renamed || filtered {
+				return
+			}
+			msg.Text = fmt.Sprintf("%s renamed the card: %s ➔ %s", mention(c, byMember), m.Italic(oldCard.Name), m.Bold(card.Name))
 		} else if oldCard.Closed != card.Closed {
 			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.closed": card.Closed}}, card)
 			updateCardMessages(c, wc, card)
+			scheduleDueReminders(c, card)
 			if cardMsgJustPosted && err == nil {
 				return
 			}
-			if !bs.Filter.Archived {
+			if !bs.Filter.Archived || filtered {
 				return
 			}
 			// archived/unarchived
@@ -597,36 +758,83 @@ func webhookHandler(c *integram.Context, wc *integram.WebhookContext) (err error
 				un = "un"
 			}
 			msg.Text = fmt.Sprintf("%s %sarchived the card", mention(c, byMember), un)
-		} else if oldCard.Due != nil {
-			// due date set/unset
-			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.due": card.Due}}, card)
+		} else if oldCard.has("due") || oldCard.has("dueComplete") || oldCard.has("start") || oldCard.has("dueReminder") {
+			// due date, start date or due reminder set/unset
+			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.due": card.Due, "val.duecomplete": card.DueComplete, "val.start": card.Start, "val.duereminder": card.DueReminder}}, card)
 			updateCardMessages(c, wc, card)
+			scheduleDueReminders(c, card)
 			if cardMsgJustPosted && err == nil {
 				return
 			}
 
-			if !bs.Filter.Due {
+			if !bs.Filter.Due || filtered {
 				return
 			}
 
 			msg.SetSilent(true)
 
-			if card.Due != nil && !card.Due.IsZero() {
-				msg.EnableHTML()
-				msg.Text = fmt.Sprintf("%s set the due date: `%v`", mention(c, byMember), decent.Relative(card.Due.In(c.User.TzLocation())))
-			} else {
-				msg.Text = fmt.Sprintf("%s removed the due date", mention(c, byMember))
+			switch {
+			case oldCard.has("due"):
+				if card.Due != nil && !card.Due.IsZero() {
+					msg.EnableHTML()
+					msg.Text = fmt.Sprintf("%s set the due date: `%v`", mention(c, byMember), decent.Relative(card.Due.In(c.User.TzLocation())))
+				} else {
+					msg.Text = fmt.Sprintf("%s removed the due date", mention(c, byMember))
+				}
+			case oldCard.has("dueComplete"):
+				if card.DueComplete {
+					msg.Text = fmt.Sprintf("%s%s marked the due date complete", markSign, mention(c, byMember))
+				} else {
+					msg.Text = fmt.Sprintf("%s marked the due date incomplete", mention(c, byMember))
+				}
+			case oldCard.has("start"):
+				if card.Start != nil && !card.Start.IsZero() {
+					msg.Text = fmt.Sprintf("%s set the start date: %s", mention(c, byMember), m.Fixed(dueText(c, card)))
+				} else {
+					msg.Text = fmt.Sprintf("%s removed the start date", mention(c, byMember))
+				}
+			default:
+				if card.DueReminder != nil && *card.DueReminder >= 0 {
+					msg.Text = fmt.Sprintf("%s set the due reminder: %s", mention(c, byMember), m.Bold(dueReminderText(*card.DueReminder)))
+				} else {
+					msg.Text = fmt.Sprintf("%s removed the due reminder", mention(c, byMember))
+				}
 			}
-		} else if oldCard.Desc != card.Desc {
-			card.Desc = cleanDesc(card.Desc)
-			if card.Desc == "" {
-				return
+		} else if oldCard.has("idAttachmentCover") {
+			// cover changed
+			card.Attachments = nil
+			if card.IdAttachmentCover != "" {
+				card.SetClient(api(c))
+				cover, err := card.GetAttachment(card.IdAttachmentCover)
+				if err != nil {
+					c.Log().WithError(err).WithField("card", card.Id).Error("can't get the card cover")
+				} else {
+					card.Attachments = []*t.Attachment{cover}
+				}
 			}
+			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.idattachmentcover": card.IdAttachmentCover, "val.attachments": card.Attachments}}, card)
+			updateCardMessages(c, wc, card)
+
+			return
+		} else if oldCard.has("desc") {
 			// description edited
+			diff := descDiff(oldCard.Desc, card.Desc)
+			card.Desc = cleanDesc(card.Desc)
 			err = c.UpdateServiceCache("card_"+card.Id, bson.M{"$set": bson.M{"val.desc": card.Desc}}, card)
 			updateCardMessages(c, wc, card)
+			if cardMsgJustPosted && err == nil {
+				return
+			}
+			if !bs.Filter.DescChanged || filtered || diff == "" {
+				return
+			}
 
-			return
+			msg.SetSilent(true)
+			if card.Desc == "" {
+				msg.Text = fmt.Sprintf("%s removed the description:\n%s", mention(c, byMember), m.Pre(diff))
+			} else {
+				msg.Text = fmt.Sprintf("%s edited the description:\n%s", mention(c, byMember), m.Pre(diff))
+			}
 		} else {
 			return
 		}
@@ -687,6 +895,14 @@ func cardReplied(c *integram.Context, cardID string) error {
 		return nil
 	}
 
+	if command, param := c.Message.GetCommand(); command != "" {
+		if ok, err := cardReplyCommand(c, cardID, command, param); ok {
+			return err
+		}
+		// other commands work as if they are sent without the reply
+		return newMessageHandler(c)
+	}
+
 	if c.Message.Document != nil {
 		if c.Message.Document.FileSize > 10*1024*1024 {
 			return c.NewMessage().SetReplyToMsgID(c.Message.MsgID).SetText("Sorry, Max file size for Trello is limited to 10MB").Send()
@@ -721,6 +937,11 @@ func cardReplied(c *integram.Context, cardID string) error {
 		return err
 	}
 
+	if _, _, ok := parseChecklistText(c.Message.Text); ok {
+		_, err := c.Service().DoJob(createChecklistFromText, c, cardID, c.Message.Text)
+		return err
+	}
+
 	if c.Message.Text != "" {
 		_, err := c.Service().DoJob(commentCard, c, cardID, c.Message.Text)
 		return err
@@ -807,15 +1028,33 @@ func removeFile(path string) error {
 	return os.Remove(path)
 }
 
+// attachmentFileTTL is how long the downloaded attachment is kept to send it to the other chats with the board integrated
+const attachmentFileTTL = time.Hour
+
+// downloadAttachment sends the attachment file to the chat. The first chat gets the file uploaded and its Telegram's file_id
+// is cached, so the other chats the board is integrated in get the file by file_id
 func downloadAttachment(c *integram.Context, cardID string, replyToMsgID int, text string, attachment attachment) error {
-	if attachment.PreviewURL != "" {
+	isImage := attachment.PreviewURL != ""
+	msg := c.NewMessage().AddEventID("attachment_"+attachment.ID).SetReplyAction(cardReplied, cardID).SetText(text).SetReplyToMsgID(replyToMsgID)
+
+	var fileID string
+	if c.ServiceCache("tg_file_"+attachment.ID, &fileID) && fileID != "" {
+		_, err := sendAttachmentMessage(c, msg, fileID, attachment.Name, isImage, true)
+		if err == nil {
+			return nil
+		}
+		c.Log().WithError(err).WithField("attachment", attachment.ID).Warn("can't reuse Telegram file_id, uploading again")
+	}
+
+	if isImage {
 		c.SendAction(tg.ChatUploadPhoto)
 	} else {
 		c.SendAction(tg.ChatUploadDocument)
 	}
 
+	// the file is downloaded from Trello once for the chats that send it before the file_id is known
 	var fileLocalPath string
-	c.User.Cache("attachment_"+attachment.ID, &fileLocalPath)
+	c.ServiceCache("attachment_"+attachment.ID, &fileLocalPath)
 
 	if fileLocalPath != "" {
 		if _, err := os.Stat(fileLocalPath); os.IsNotExist(err) {
@@ -829,13 +1068,98 @@ func downloadAttachment(c *integram.Context, cardID string, replyToMsgID int, te
 		if err != nil {
 			return err
 		}
-		c.User.SetCache("attachment_"+attachment.ID, fileLocalPath, time.Hour*24)
+		err = c.SetServiceCache("attachment_"+attachment.ID, fileLocalPath, attachmentFileTTL)
+		if err != nil {
+			c.Log().WithError(err).Error("can't cache the attachment path")
+		}
+		c.Service().SheduleJob(removeFile, 0, time.Now().Add(attachmentFileTTL), fileLocalPath)
+	}
+
+	fileID, err := sendAttachmentMessage(c, msg, fileLocalPath, attachment.Name, isImage, false)
+	if err != nil {
+		return err
+	}
+	if fileID == "" {
+		return nil
+	}
+	return c.SetServiceCache("tg_file_"+attachment.ID, fileID, time.Hour*24*30)
+}
+
+// sendAttachmentMessage sends the file synchronously, the message queue neither sends by file_id nor returns the sent file_id.
+// file is the local path or the file_id when reuse is set. The message is saved like the queue does to keep its reply action and event ID
+func sendAttachmentMessage(c *integram.Context, msg *integram.OutgoingMessage, file string, fileName string, isImage bool, reuse bool) (fileID string, err error) {
+	var tgMsg tg.Message
+
+	if isImage {
+		cfg := tg.NewPhotoShare(msg.ChatID, file)
+		if !reuse {
+			cfg = tg.NewPhotoUpload(msg.ChatID, file)
+			cfg.FileName = fileName
+		}
+		cfg.Caption = msg.Text
+		cfg.ReplyToMessageID = msg.ReplyToMsgID
+		tgMsg, err = c.Bot().API.Send(cfg)
+	} else {
+		cfg := tg.NewDocumentShare(msg.ChatID, file)
+		if !reuse {
+			cfg = tg.NewDocumentUpload(msg.ChatID, file)
+			cfg.FileName = fileName
+		}
+		cfg.Caption = msg.Text
+		cfg.ReplyToMessageID = msg.ReplyToMsgID
+		tgMsg, err = c.Bot().API.Send(cfg)
+	}
+	if err != nil {
+		return "", err
 	}
-	if attachment.PreviewURL != "" {
-		return c.NewMessage().SetReplyAction(cardReplied, cardID).SetText(text).SetReplyToMsgID(replyToMsgID).SetImage(fileLocalPath, attachment.Name).EnableFileRemoveAfter().Send()
+
+	if tgMsg.Photo != nil && len(*tgMsg.Photo) > 0 {
+		// the last one is the largest size
+		fileID = (*tgMsg.Photo)[len(*tgMsg.Photo)-1].FileID
+	} else if tgMsg.Document != nil {
+		fileID = tgMsg.Document.FileID
+	}
+
+	msg.MsgID = tgMsg.MessageID
+	msg.Date = time.Now()
+	msg.TextHash = msg.GetTextHash()
+	msg.Text = ""
+
+	err = c.Db().C("messages").Insert(msg)
+	if err != nil {
+		c.Log().WithError(err).Error("can't save the attachment message")
+	}
+	return fileID, nil
+}
+
+var trelloLinkRe = regexp.MustCompile(`https?://(?:www\.)?trello\.com/([bc])/([0-9a-zA-Z]+)`)
+
+// linkAttachmentText renders the link attachment as the referenced card or board name for Trello links
+// and as the attachment name with host for the others
+func linkAttachmentText(c *integram.Context, link string, name string) string {
+	if match := trelloLinkRe.FindStringSubmatch(link); match != nil {
+		api := api(c)
+		if match[1] == "c" {
+			if linked, err := api.Card(match[2]); err == nil {
+				return "the card " + m.URL(linked.Name, link) + " " + m.Italic(cardPath(linked))
+			}
+		} else if linked, err := api.Board(match[2]); err == nil {
+			return "the board " + m.URL(linked.Name, link)
+		}
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return m.EncodeEntities(link)
+	}
+	host := strings.TrimPrefix(u.Host, "www.")
+
+	// the page itself is never fetched: the link is set by any board member and may point to the internal hosts
+	if name == "" || name == link {
+		return m.URL(link, link)
 	}
 
-	return c.NewMessage().SetReplyAction(cardReplied, cardID).SetText(text).SetReplyToMsgID(replyToMsgID).SetDocument(fileLocalPath, attachment.Name).EnableFileRemoveAfter().Send()
+	return m.URL(name, c.WebPreview(name, host, "", link, "")) + " • " + m.Italic(host)
 }
 
 func cardMessage(c *integram.Context, cardID string) (*integram.Message, error) {